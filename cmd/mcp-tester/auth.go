@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authStatusCmd)
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage OAuth tokens for protected MCP servers",
+}
+
+// authSettings resolves the selected profile and its token cache key.
+func authSettings() (Profile, *auth.Store, string, error) {
+	config, _ := loadConfig("mcp-tester.yml")
+	settings, err := resolveSettings(config, profile, command, url, transportType)
+	if err != nil {
+		return Profile{}, nil, "", err
+	}
+	if settings.OAuth == nil {
+		return Profile{}, nil, "", fmt.Errorf("profile has no oauth configuration")
+	}
+	store, err := auth.DefaultStore()
+	if err != nil {
+		return Profile{}, nil, "", fmt.Errorf("failed to locate token cache: %w", err)
+	}
+	return settings, store, tokenCacheKey(settings), nil
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Authorize against the server and cache the access token",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, store, key, err := authSettings()
+		if err != nil {
+			return err
+		}
+		// Start from a clean cache so the flow always runs.
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		transport, err := getTransport(ctx, settings)
+		if err != nil {
+			return err
+		}
+		client := getClient(verbose, os.Stdout)
+		session, err := client.Connect(ctx, transport, nil)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		if err := session.Ping(ctx, &mcp.PingParams{}); err != nil {
+			return fmt.Errorf("ping failed: %w", err)
+		}
+		fmt.Println("Login successful, token cached.")
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the cached token of the profile",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, store, key, err := authSettings()
+		if err != nil {
+			return err
+		}
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+		fmt.Println("Token removed.")
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the cached token of the profile",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, store, key, err := authSettings()
+		if err != nil {
+			return err
+		}
+		e, err := store.Load(key)
+		if err != nil {
+			return err
+		}
+		if e == nil || e.Token == nil {
+			fmt.Println("Not logged in.")
+			return nil
+		}
+		fmt.Printf("Resource:  %s\n", e.Resource)
+		fmt.Printf("Flow:      %s\n", e.Flow)
+		fmt.Printf("Client ID: %s\n", e.ClientID)
+		if !e.Token.Expiry.IsZero() {
+			state := "valid"
+			if time.Now().After(e.Token.Expiry) {
+				state = "expired"
+			}
+			fmt.Printf("Expires:   %s (%s)\n", e.Token.Expiry.Format(time.RFC3339), state)
+		}
+		fmt.Printf("Refresh:   %t\n", e.Token.RefreshToken != "")
+		return nil
+	},
+}
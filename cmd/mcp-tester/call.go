@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
-	"github.com/hmsoft0815/mlc_mcptester/internal/client"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
@@ -33,22 +33,23 @@ var callCmd = &cobra.Command{
 		}
 
 		// Resolve settings from profile or flags
-		c, u, err := resolveSettings(config, profile, command, url)
+		settings, err := resolveSettings(config, profile, command, url, transportType)
 		if err != nil {
 			return err
 		}
 
 		// Get appropriate transport (stdio or sse).
-		transport, err := getTransport(ctx, c, u)
+		transport, err := getTransport(ctx, settings)
 		if err != nil {
 			return err
 		}
 
 		// Set up the client.
-		mcpClient := getClient(verbose)
+		mcpClient := getClient(verbose, os.Stdout)
 
-		// Create a session with the server.
-		session, err := mcpClient.Connect(ctx, transport, nil)
+		// Create a session with the server. The raw client shares its connection.
+		rpc := newRaw(transport, os.Stdout)
+		session, err := mcpClient.Connect(ctx, rpc, nil)
 		if err != nil {
 			return fmt.Errorf("failed to connect: %w", err)
 		}
@@ -67,7 +68,7 @@ var callCmd = &cobra.Command{
 		if raw {
 			fmt.Println("--- RAW MODE ---")
 			meta := map[string]any{"progressToken": fmt.Sprintf("script-progress-%s", toolName)}
-			result, err := client.CallToolRaw(ctx, session, toolName, toolArgs, meta)
+			result, err := rpc.CallTool(ctx, toolName, toolArgs, meta)
 			if err != nil {
 				return fmt.Errorf("failed to call tool (raw): %w", err)
 			}
@@ -1,17 +1,45 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/hmsoft0815/mlc_mcptester/internal/auth"
 	"gopkg.in/yaml.v3"
 )
 
 // Profile represents a server configuration profile.
+//
+// For stdio servers, Command is run through "sh -c" unless Args is set, in which
+// case Command is executed directly with Args as its argument list.
+// The HTTP and TLS settings only apply to remote (sse, http) profiles.
 type Profile struct {
-	Command  string `yaml:"command,omitempty"`
-	URL      string `yaml:"url,omitempty"`
-	Disabled bool   `yaml:"disabled,omitempty"`
+	// Name is the key of the profile in mcp-tester.yml, empty for ad-hoc settings.
+	Name string `yaml:"-"`
+	// dir is the directory of the config file, empty for ad-hoc settings.
+	dir string
+
+	Command   string            `yaml:"command,omitempty"`
+	Args      []string          `yaml:"args,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	EnvFile   string            `yaml:"envFile,omitempty"`
+	Cwd       string            `yaml:"cwd,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Transport string            `yaml:"transport,omitempty"`
+	Disabled  bool              `yaml:"disabled,omitempty"`
+
+	Headers            map[string]string `yaml:"headers,omitempty"`
+	BearerToken        string            `yaml:"bearerToken,omitempty"`
+	TLSCA              string            `yaml:"tlsCA,omitempty"`
+	TLSCert            string            `yaml:"tlsCert,omitempty"`
+	TLSKey             string            `yaml:"tlsKey,omitempty"`
+	InsecureSkipVerify bool              `yaml:"insecureSkipVerify,omitempty"`
+
+	OAuth *auth.Config `yaml:"oauth,omitempty"`
 }
 
 // Config represents the tool's configuration file.
@@ -38,6 +66,14 @@ func loadConfig(path string) (*Config, error) {
 	if config.Profiles == nil {
 		config.Profiles = make(map[string]Profile)
 	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for name, p := range config.Profiles {
+		p.dir = dir
+		config.Profiles[name] = p
+	}
 
 	return &config, nil
 }
@@ -53,16 +89,109 @@ func saveConfig(path string, config *Config) error {
 }
 
 // resolveSettings applies a profile if specified, otherwise uses the command line flags.
-func resolveSettings(config *Config, profileName string, cmdArg, urlArg string) (string, string, error) {
+// An explicit --transport flag always overrides the transport stored in the profile.
+func resolveSettings(config *Config, profileName string, cmdArg, urlArg, transportArg string) (Profile, error) {
 	if profileName != "" {
 		profile, ok := config.Profiles[profileName]
 		if !ok {
-			return "", "", fmt.Errorf("profile not found: %s", profileName)
+			return Profile{}, fmt.Errorf("profile not found: %s", profileName)
 		}
 		if profile.Disabled {
-			return "", "", fmt.Errorf("profile '%s' is disabled", profileName)
+			return Profile{}, fmt.Errorf("profile '%s' is disabled", profileName)
+		}
+		if transportArg != "" {
+			profile.Transport = transportArg
+		}
+		profile.Name = profileName
+		return profile, nil
+	}
+	return Profile{Command: cmdArg, URL: urlArg, Transport: transportArg}, nil
+}
+
+// loadEnvFile reads KEY=VALUE pairs from a dotenv style file.
+// Empty lines and lines starting with '#' are ignored, an optional "export "
+// prefix is stripped and values may be wrapped in single or double quotes.
+func loadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		env[key] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// workDir returns the profile's cwd, resolved against the directory of the
+// config file if it is relative, or that directory if cwd is not set.
+func workDir(p Profile) string {
+	if filepath.IsAbs(p.Cwd) {
+		return p.Cwd
+	}
+	return filepath.Join(p.dir, p.Cwd)
+}
+
+// envFilePath returns the path of the profile's envFile. A relative path is
+// resolved against the server's working directory, see workDir.
+func envFilePath(p Profile) string {
+	if filepath.IsAbs(p.EnvFile) {
+		return p.EnvFile
+	}
+	return filepath.Join(workDir(p), p.EnvFile)
+}
+
+// processEnv builds the environment for a stdio server: the tester's own
+// environment, overlaid by the profile's envFile and finally its env map.
+// Values in env may reference other variables using ${VAR} syntax.
+func processEnv(p Profile) ([]string, error) {
+	environ := os.Environ()
+	if p.EnvFile != "" {
+		fileEnv, err := loadEnvFile(envFilePath(p))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load env file: %w", err)
+		}
+		environ = appendEnv(environ, fileEnv, nil)
+	}
+	return appendEnv(environ, p.Env, os.ExpandEnv), nil
+}
+
+// appendEnv appends the variables in sorted order so the result is deterministic.
+// Later entries win when exec.Cmd deduplicates the environment.
+func appendEnv(environ []string, vars map[string]string, expand func(string) string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := vars[k]
+		if expand != nil {
+			v = expand(v)
 		}
-		return profile.Command, profile.URL, nil
+		environ = append(environ, k+"="+v)
 	}
-	return cmdArg, urlArg, nil
+	return environ
 }
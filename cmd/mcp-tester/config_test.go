@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -57,13 +61,200 @@ func TestResolveSettings(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		c, u, err := resolveSettings(config, tt.profile, tt.cmd, tt.url)
+		p, err := resolveSettings(config, tt.profile, tt.cmd, tt.url, "")
 		if (err != nil) != tt.wantErr {
 			t.Errorf("resolveSettings(%q) error = %v; wantErr %v", tt.profile, err, tt.wantErr)
 			continue
 		}
-		if !tt.wantErr && (c != tt.wantCmd || u != tt.wantURL) {
-			t.Errorf("resolveSettings(%q) = (%q, %q); want (%q, %q)", tt.profile, c, u, tt.wantCmd, tt.wantURL)
+		if !tt.wantErr && (p.Command != tt.wantCmd || p.URL != tt.wantURL) {
+			t.Errorf("resolveSettings(%q) = (%q, %q); want (%q, %q)", tt.profile, p.Command, p.URL, tt.wantCmd, tt.wantURL)
 		}
 	}
 }
+
+func TestTransportKind(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		want    string
+		wantErr bool
+	}{
+		{Profile{Command: "ls"}, transportStdio, false},
+		{Profile{URL: "http://localhost/sse"}, transportSSE, false},
+		{Profile{URL: "http://localhost/mcp", Transport: "http"}, transportStreamable, false},
+		{Profile{URL: "http://localhost/mcp", Transport: "streamable"}, transportStreamable, false},
+		{Profile{URL: "http://localhost/mcp", Transport: "websocket"}, "", true},
+		{Profile{}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := transportKind(tt.profile)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("transportKind(%+v) error = %v; wantErr %v", tt.profile, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("transportKind(%+v) = %q; want %q", tt.profile, got, tt.want)
+		}
+	}
+
+	// The --transport flag overrides the profile setting.
+	config := &Config{Profiles: map[string]Profile{"remote": {URL: "http://localhost/mcp", Transport: "sse"}}}
+	p, err := resolveSettings(config, "remote", "", "", "http")
+	if err != nil || p.Transport != "http" {
+		t.Errorf("resolveSettings override = (%+v, %v); want transport http", p, err)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	content := `
+# comment
+API_KEY=secret
+export REGION = "eu-west"
+QUOTED='a b c'
+`
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := loadEnvFile(path)
+	if err != nil {
+		t.Fatalf("loadEnvFile error = %v", err)
+	}
+	want := map[string]string{"API_KEY": "secret", "REGION": "eu-west", "QUOTED": "a b c"}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("env[%q] = %q; want %q", k, env[k], v)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("NO_EQUALS"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadEnvFile(path); err == nil {
+		t.Error("expected error for line without '='")
+	}
+}
+
+func TestBuildCommand(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envFile, []byte("FROM_FILE=1\nOVERRIDE=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MCP_TESTER_HOST", "example.org")
+
+	// Shell mode
+	cmd, err := buildCommand(context.Background(), Profile{Command: "echo hi"})
+	if err != nil {
+		t.Fatalf("buildCommand error = %v", err)
+	}
+	if !slices.Equal(cmd.Args, []string{"sh", "-c", "echo hi"}) {
+		t.Errorf("shell args = %v", cmd.Args)
+	}
+	if cmd.Env != nil {
+		t.Errorf("expected inherited environment, got %v", cmd.Env)
+	}
+
+	// Argv mode with environment and working directory
+	p := Profile{
+		Command: "server",
+		Args:    []string{"--name", "with space"},
+		EnvFile: envFile,
+		Env:     map[string]string{"OVERRIDE": "profile", "URL": "https://${MCP_TESTER_HOST}/api"},
+		Cwd:     dir,
+	}
+	cmd, err = buildCommand(context.Background(), p)
+	if err != nil {
+		t.Fatalf("buildCommand error = %v", err)
+	}
+	if !slices.Equal(cmd.Args, []string{"server", "--name", "with space"}) {
+		t.Errorf("argv = %v", cmd.Args)
+	}
+	if cmd.Dir != dir {
+		t.Errorf("Dir = %q; want %q", cmd.Dir, dir)
+	}
+	// exec.Cmd keeps the last value of duplicated keys.
+	last := map[string]string{}
+	for _, kv := range cmd.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			last[k] = v
+		}
+	}
+	if last["FROM_FILE"] != "1" || last["OVERRIDE"] != "profile" || last["URL"] != "https://example.org/api" {
+		t.Errorf("unexpected environment: FROM_FILE=%q OVERRIDE=%q URL=%q", last["FROM_FILE"], last["OVERRIDE"], last["URL"])
+	}
+
+	if _, err := buildCommand(context.Background(), Profile{Command: "x", EnvFile: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected error for missing env file")
+	}
+}
+
+func TestEnvFilePath(t *testing.T) {
+	root := t.TempDir()
+	server := filepath.Join(root, "server")
+	tests := []struct {
+		p    Profile
+		want string
+	}{
+		{Profile{EnvFile: ".env", dir: root}, filepath.Join(root, ".env")},
+		{Profile{EnvFile: ".env", Cwd: "server", dir: root}, filepath.Join(server, ".env")},
+		{Profile{EnvFile: ".env", Cwd: server, dir: "/elsewhere"}, filepath.Join(server, ".env")},
+		{Profile{EnvFile: "/etc/app.env", Cwd: "server", dir: root}, "/etc/app.env"},
+		{Profile{EnvFile: ".env"}, ".env"},
+	}
+	for _, tt := range tests {
+		if got := envFilePath(tt.p); got != tt.want {
+			t.Errorf("envFilePath(%+v) = %q; want %q", tt.p, got, tt.want)
+		}
+	}
+
+	// Profiles loaded from a config file resolve against its directory.
+	config := filepath.Join(root, "mcp-tester.yml")
+	if err := os.WriteFile(config, []byte("profiles:\n  local:\n    command: x\n    envFile: .env\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := envFilePath(c.Profiles["local"]); got != filepath.Join(root, ".env") {
+		t.Errorf("envFilePath of loaded profile = %q", got)
+	}
+}
+
+func TestRelativeCwd(t *testing.T) {
+	// The config lives outside the current directory; the server starts in
+	// its cwd relative to the config and reads the env file from there.
+	root := t.TempDir()
+	server := filepath.Join(root, "server")
+	if err := os.Mkdir(server, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(server, ".env"), []byte("FROM_FILE=server\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(root, "mcp-tester.yml")
+	if err := os.WriteFile(config, []byte("profiles:\n  local:\n    command: x\n    cwd: server\n    envFile: .env\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := buildCommand(context.Background(), c.Profiles["local"])
+	if err != nil {
+		t.Fatalf("buildCommand error = %v", err)
+	}
+	if cmd.Dir != server {
+		t.Errorf("Dir = %q; want %q", cmd.Dir, server)
+	}
+	if !slices.Contains(cmd.Env, "FROM_FILE=server") {
+		t.Error("env file was not read from the server's working directory")
+	}
+
+	// Without cwd the server inherits the tester's working directory.
+	if cmd, _ := buildCommand(context.Background(), Profile{Command: "x", dir: root}); cmd.Dir != "" {
+		t.Errorf("Dir without cwd = %q; want inherited", cmd.Dir)
+	}
+}
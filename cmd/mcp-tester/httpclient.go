@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/auth"
+)
+
+// headerTransport adds a fixed set of headers to every outgoing request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	return t.base.RoundTrip(req)
+}
+
+// hasHTTPOptions reports whether the profile customizes the HTTP client.
+func hasHTTPOptions(p Profile) bool {
+	return len(p.Headers) > 0 || p.BearerToken != "" || p.TLSCA != "" ||
+		p.TLSCert != "" || p.TLSKey != "" || p.InsecureSkipVerify || p.OAuth != nil
+}
+
+// newHTTPClient builds the HTTP client for remote transports from the profile's
+// headers, bearer token, OAuth and TLS settings. It returns nil if the profile does not
+// configure any of them, so the SDK falls back to http.DefaultClient.
+// Header values and the bearer token may reference environment variables using ${VAR}.
+func newHTTPClient(p Profile) (*http.Client, error) {
+	if !hasHTTPOptions(p) {
+		return nil, nil
+	}
+
+	tlsConfig, err := newTLSConfig(p)
+	if err != nil {
+		return nil, err
+	}
+	if p.OAuth != nil && p.BearerToken != "" {
+		return nil, fmt.Errorf("bearerToken and oauth cannot be combined")
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = base
+	if p.OAuth != nil {
+		if p.OAuth.ClientSecret != "" {
+			cfg := *p.OAuth
+			cfg.ClientSecret = os.ExpandEnv(cfg.ClientSecret)
+			p.OAuth = &cfg
+		}
+		store, err := auth.DefaultStore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate token cache: %w", err)
+		}
+		rt = auth.NewTransport(base, p.URL, p.OAuth, store, tokenCacheKey(p))
+	}
+
+	headers := make(http.Header)
+	for k, v := range p.Headers {
+		headers.Set(k, os.ExpandEnv(v))
+	}
+	if p.BearerToken != "" {
+		token := os.ExpandEnv(p.BearerToken)
+		if token == "" {
+			return nil, fmt.Errorf("bearer token %q expands to an empty string", p.BearerToken)
+		}
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	return &http.Client{Transport: &headerTransport{base: rt, headers: headers}}, nil
+}
+
+// tokenCacheKey identifies the cached OAuth token of a profile. Ad-hoc --url
+// connections are cached per URL.
+func tokenCacheKey(p Profile) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.URL
+}
+
+// newTLSConfig creates the TLS configuration for a private CA and/or a client certificate.
+func newTLSConfig(p Profile) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: p.InsecureSkipVerify,
+	}
+
+	if p.TLSCA != "" {
+		pem, err := os.ReadFile(p.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tlsCA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tlsCA %s contains no PEM certificates", p.TLSCA)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if p.TLSCert != "" || p.TLSKey != "" {
+		if p.TLSCert == "" || p.TLSKey == "" {
+			return nil, fmt.Errorf("tlsCert and tlsKey must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(p.TLSCert, p.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
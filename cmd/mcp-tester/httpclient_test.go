@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	if c, err := newHTTPClient(Profile{URL: "http://localhost"}); c != nil || err != nil {
+		t.Errorf("expected nil client without options, got (%v, %v)", c, err)
+	}
+
+	var gotAuth, gotCustom string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotCustom = r.Header.Get("X-Gateway")
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MCP_TESTER_TOKEN", "s3cr3t")
+
+	client, err := newHTTPClient(Profile{
+		URL:         srv.URL,
+		Headers:     map[string]string{"X-Gateway": "team-a"},
+		BearerToken: "${MCP_TESTER_TOKEN}",
+		TLSCA:       caFile,
+	})
+	if err != nil {
+		t.Fatalf("newHTTPClient error = %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with private CA failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotAuth != "Bearer s3cr3t" || gotCustom != "team-a" {
+		t.Errorf("headers = (%q, %q); want (%q, %q)", gotAuth, gotCustom, "Bearer s3cr3t", "team-a")
+	}
+
+	// Without the CA the self-signed certificate must be rejected.
+	client, _ = newHTTPClient(Profile{URL: srv.URL, Headers: map[string]string{"X-Gateway": "team-a"}})
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("expected TLS verification error without tlsCA")
+	}
+
+	if _, err := newHTTPClient(Profile{TLSCert: "cert.pem"}); err == nil {
+		t.Error("expected error when tlsKey is missing")
+	}
+	if _, err := newHTTPClient(Profile{BearerToken: "${MCP_TESTER_UNSET_TOKEN}"}); err == nil {
+		t.Error("expected error for empty bearer token")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
 	"github.com/spf13/cobra"
@@ -30,15 +31,15 @@ var inspectCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 		config, _ := loadConfig("mcp-tester.yml")
-		c, u, err := resolveSettings(config, profile, command, url)
+		settings, err := resolveSettings(config, profile, command, url, transportType)
 		if err != nil {
 			return err
 		}
-		transport, err := getTransport(ctx, c, u)
+		transport, err := getTransport(ctx, settings)
 		if err != nil {
 			return err
 		}
-		client := getClient(verbose)
+		client := getClient(verbose, os.Stdout)
 		session, err := client.Connect(ctx, transport, nil)
 		if err != nil {
 			return err
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <script|directory|glob>...",
+	Short: "Check test scripts without running them",
+	Long: `Check test scripts without running them.
+
+Reports syntax errors such as unterminated heredocs or blocks, unknown
+commands, wrong argument counts and variables used before set_var or
+input_var. With --profile, --command or --url the tools of the server are
+listed and call_tool names and key: arguments are checked against them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scripts, err := discoverScripts(args)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		var tools []*mcp.Tool
+		if profile != "" || command != "" || url != "" {
+			if tools, err = listServerTools(context.Background()); err != nil {
+				return &exitError{code: exitConnection, err: err}
+			}
+		}
+
+		var diags []scripting.Diagnostic
+		for _, script := range scripts {
+			d, err := scripting.Lint(script, tools)
+			if err != nil {
+				return err
+			}
+			diags = append(diags, d...)
+		}
+
+		if format == "json" {
+			if diags == nil {
+				diags = []scripting.Diagnostic{}
+			}
+			out, _ := json.MarshalIndent(diags, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			for _, d := range diags {
+				fmt.Println(d)
+			}
+			fmt.Print(i18n.T(i18n.MsgLintSummary, len(scripts), len(diags)))
+		}
+		if len(diags) > 0 {
+			return fmt.Errorf("%d problems found", len(diags))
+		}
+		return nil
+	},
+}
+
+// listServerTools connects to the configured server and returns its tools.
+func listServerTools(ctx context.Context) ([]*mcp.Tool, error) {
+	config, err := loadConfig("mcp-tester.yml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	settings, err := resolveSettings(config, profile, command, url, transportType)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := getTransport(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	session, err := getClient(verbose, os.Stdout).Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer session.Close()
+
+	result, err := session.ListTools(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	return result.Tools, nil
+}
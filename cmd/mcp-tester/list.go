@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
 	"github.com/spf13/cobra"
@@ -21,15 +22,15 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
-		c, u, err := resolveSettings(config, profile, command, url)
+		settings, err := resolveSettings(config, profile, command, url, transportType)
 		if err != nil {
 			return err
 		}
-		transport, err := getTransport(ctx, c, u)
+		transport, err := getTransport(ctx, settings)
 		if err != nil {
 			return err
 		}
-		client := getClient(verbose)
+		client := getClient(verbose, os.Stdout)
 		session, err := client.Connect(ctx, transport, nil)
 		if err != nil {
 			return fmt.Errorf("failed to connect: %w", err)
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
@@ -20,15 +21,15 @@ var loggingCmd = &cobra.Command{
 		level := args[0]
 		ctx := context.Background()
 		config, _ := loadConfig("mcp-tester.yml")
-		c, u, err := resolveSettings(config, profile, command, url)
+		settings, err := resolveSettings(config, profile, command, url, transportType)
 		if err != nil {
 			return err
 		}
-		transport, err := getTransport(ctx, c, u)
+		transport, err := getTransport(ctx, settings)
 		if err != nil {
 			return err
 		}
-		client := getClient(verbose)
+		client := getClient(verbose, os.Stdout)
 		session, err := client.Connect(ctx, transport, nil)
 		if err != nil {
 			return err
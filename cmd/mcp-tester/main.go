@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -13,13 +14,16 @@ import (
 var (
 	command       string
 	url           string
+	transportType string
 	profile       string
 	verbose       bool
 	raw           bool
+	noValidate    bool
 	checkIcons    bool
 	downloadIcons string
 	lang          string
 	format        string
+	traceFile     string
 )
 
 // rootCmd represents the base command when called without any subcommands.
@@ -36,20 +40,55 @@ var rootCmd = &cobra.Command{
 func init() {
 	// Persistent flags are available to every subcommand.
 	rootCmd.PersistentFlags().StringVarP(&command, "command", "c", "", "Command to run the MCP server (stdio)")
-	rootCmd.PersistentFlags().StringVarP(&url, "url", "u", "", "URL of the MCP server (sse)")
+	rootCmd.PersistentFlags().StringVarP(&url, "url", "u", "", "URL of the MCP server (sse or http)")
+	rootCmd.PersistentFlags().StringVarP(&transportType, "transport", "t", "", "Transport to use (stdio, sse, http). Defaults to stdio for --command and sse for --url")
 	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "Profile from mcp-tester.yml to use")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().BoolVarP(&raw, "raw", "r", false, "Enable raw mode to bypass strict SDK unmarshaling")
+	rootCmd.PersistentFlags().BoolVar(&noValidate, "no-validate", false, "Send script tool arguments without checking them against the tool's inputSchema")
 	rootCmd.PersistentFlags().BoolVar(&checkIcons, "check-icons", false, "Check if icon URIs are reachable")
 	rootCmd.PersistentFlags().StringVar(&downloadIcons, "download-icons", "", "Download icons to the specified directory")
 	rootCmd.PersistentFlags().StringVar(&lang, "lang", "en", "Language for output (en, de)")
 	rootCmd.PersistentFlags().StringVar(&format, "format", "text", "Output format (text, json)")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace", "", "Record all JSON-RPC messages to the given JSONL file")
 }
 
+// Process exit codes. Commands other than test only use exitFailure.
+const (
+	exitOK         = 0
+	exitFailure    = 1 // assertion or command failures, and general errors
+	exitSyntax     = 2 // a script could not be parsed
+	exitConnection = 3 // the server could not be started or reached
+)
+
+// exitError is an error that ends the process with a specific exit code. Without
+// err the command has already reported the failure and nothing is printed.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+func (e *exitError) Unwrap() error { return e.err }
+
 func main() {
 	// Execute the root command.
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	err := rootCmd.Execute()
+	closeTrace()
+	if err != nil {
+		if msg := err.Error(); msg != "" {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		code := exitFailure
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		os.Exit(code)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -18,12 +19,27 @@ func init() {
 	profileCmd.AddCommand(profileDisableCmd)
 
 	profileAddCmd.Flags().StringVarP(&addCommand, "command", "c", "", "Command to run the MCP server (stdio)")
-	profileAddCmd.Flags().StringVarP(&addURL, "url", "u", "", "URL of the MCP server (sse)")
+	profileAddCmd.Flags().StringVarP(&addURL, "url", "u", "", "URL of the MCP server (sse or http)")
+	profileAddCmd.Flags().StringVarP(&addTransport, "transport", "t", "", "Transport to use (stdio, sse, http)")
+	profileAddCmd.Flags().StringArrayVar(&addArgs, "arg", nil, "Argument passed to the command without a shell (repeatable)")
+	profileAddCmd.Flags().StringArrayVarP(&addEnv, "env", "e", nil, "Environment variable KEY=VALUE for the server (repeatable)")
+	profileAddCmd.Flags().StringVar(&addEnvFile, "env-file", "", "File with KEY=VALUE lines loaded into the server environment")
+	profileAddCmd.Flags().StringVar(&addCwd, "cwd", "", "Working directory of the server process")
+	profileAddCmd.Flags().StringArrayVarP(&addHeaders, "header", "H", nil, "HTTP header 'Name: value' for remote servers (repeatable)")
+	profileAddCmd.Flags().StringVar(&addBearerToken, "bearer-token", "", "Bearer token for remote servers (supports ${ENV})")
 }
 
 var (
-	addCommand string
-	addURL     string
+	addCommand   string
+	addURL       string
+	addTransport string
+	addArgs      []string
+	addEnv       []string
+	addEnvFile   string
+	addCwd       string
+
+	addHeaders     []string
+	addBearerToken string
 )
 
 var profileCmd = &cobra.Command{
@@ -45,10 +61,40 @@ var profileAddCmd = &cobra.Command{
 			return err
 		}
 
-		config.Profiles[name] = Profile{
-			Command: addCommand,
-			URL:     addURL,
+		p := Profile{
+			Command:   addCommand,
+			Args:      addArgs,
+			EnvFile:   addEnvFile,
+			Cwd:       addCwd,
+			URL:       addURL,
+			Transport: addTransport,
+
+			BearerToken: addBearerToken,
+		}
+		for _, h := range addHeaders {
+			key, val, ok := strings.Cut(h, ":")
+			if !ok {
+				return fmt.Errorf("invalid --header %q, expected 'Name: value'", h)
+			}
+			if p.Headers == nil {
+				p.Headers = make(map[string]string)
+			}
+			p.Headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		for _, kv := range addEnv {
+			key, val, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("invalid --env %q, expected KEY=VALUE", kv)
+			}
+			if p.Env == nil {
+				p.Env = make(map[string]string)
+			}
+			p.Env[key] = val
 		}
+		if _, err := transportKind(p); err != nil {
+			return err
+		}
+		config.Profiles[name] = p
 
 		if err := saveConfig("mcp-tester.yml", config); err != nil {
 			return err
@@ -112,10 +158,12 @@ var profileListCmd = &cobra.Command{
 			if p.Disabled {
 				status = "disabled"
 			}
-			pType := "stdio"
+			pType, err := transportKind(p)
+			if err != nil {
+				pType = "invalid"
+			}
 			pValue := p.Command
-			if p.URL != "" {
-				pType = "sse"
+			if pType != transportStdio && p.URL != "" {
 				pValue = p.URL
 			}
 			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, status, pType, pValue)
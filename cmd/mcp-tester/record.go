@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+	"github.com/spf13/cobra"
+)
+
+var recordOutput string
+
+func init() {
+	recordCmd.Flags().StringVarP(&recordOutput, "output", "o", "", "Path of the script to write")
+	_ = recordCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(recordCmd)
+}
+
+// recordCmd runs an interactive session and writes the commands and generated assertions to a script.
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record an interactive session as a test script",
+	Long: `Record an interactive session as a test script.
+
+Type script commands (call_tool, read_resource, rpc, ping, set_var, ...) one per
+line. Every successful command is executed against the server and written to the
+output file, followed by assert_equals/assert_contains lines generated from the
+response. Finish with "exit" or Ctrl-D.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig("mcp-tester.yml")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		settings, err := resolveSettings(config, profile, command, url, transportType)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		transport, err := getTransport(ctx, settings)
+		if err != nil {
+			return err
+		}
+		rpc := newRaw(transport, os.Stdout)
+		session, err := getClient(verbose, os.Stdout).Connect(ctx, rpc, nil)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		defer session.Close()
+
+		out, err := os.Create(recordOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create script: %w", err)
+		}
+		defer out.Close()
+		fmt.Fprintf(out, "// Recorded with mcp-tester on %s\n\n", time.Now().Format("2006-01-02 15:04"))
+
+		runner := scripting.NewRunner(session, rpc, raw)
+		runner.ValidateInput = !noValidate
+		recorder := scripting.NewRecorder(runner, out)
+		fmt.Printf("Recording to %s. Type script commands, \"exit\" to finish.\n", recordOutput)
+		scanner := bufio.NewScanner(os.Stdin)
+		for {
+			if recorder.Pending() {
+				fmt.Print("... ")
+			} else {
+				fmt.Print("record> ")
+			}
+			if !scanner.Scan() {
+				fmt.Println()
+				break
+			}
+			line := scanner.Text()
+			if !recorder.Pending() && (strings.TrimSpace(line) == "exit" || strings.TrimSpace(line) == "quit") {
+				break
+			}
+			if err := recorder.Record(ctx, line); err != nil {
+				fmt.Printf("Error: %v (not recorded)\n", err)
+			}
+		}
+		if err := recorder.Close(ctx); err != nil {
+			fmt.Printf("Teardown error: %v\n", err)
+		}
+		fmt.Printf("Script written to %s\n", recordOutput)
+		return scanner.Err()
+	},
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/trace"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayIgnore  []string
+	replayTimeout time.Duration
+)
+
+func init() {
+	replayCmd.Flags().StringArrayVar(&replayIgnore, "ignore", nil, "Dot path excluded from the comparison, e.g. 'result.content.*.timestamp' (repeatable)")
+	replayCmd.Flags().DurationVar(&replayTimeout, "timeout", 30*time.Second, "Maximum wait for each response")
+	rootCmd.AddCommand(replayCmd)
+}
+
+// replayCmd re-runs the client side of a --trace recording and compares the responses.
+var replayCmd = &cobra.Command{
+	Use:   "replay <trace.jsonl>",
+	Short: "Replay a recorded trace against an MCP server and report differences",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := trace.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read trace: %w", err)
+		}
+
+		config, err := loadConfig("mcp-tester.yml")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		settings, err := resolveSettings(config, profile, command, url, transportType)
+		if err != nil {
+			return err
+		}
+		// Each recorded connection contains its own initialize handshake, so
+		// connections are used directly instead of through an SDK session.
+		connect := func(ctx context.Context) (mcp.Connection, error) {
+			transport, err := getTransport(ctx, settings)
+			if err != nil {
+				return nil, err
+			}
+			return transport.Connect(ctx)
+		}
+		results, replayErr := trace.ReplayConnections(context.Background(), connect, records, trace.ReplayOptions{
+			Ignore:  replayIgnore,
+			Timeout: replayTimeout,
+		})
+
+		failed := 0
+		for _, res := range results {
+			if res.Status == trace.StatusDiff || res.Status == trace.StatusError {
+				failed++
+			}
+		}
+		if format == "json" {
+			out, _ := json.MarshalIndent(results, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			printReplayReport(results, failed, len(trace.Connections(records)) > 1)
+		}
+
+		if replayErr != nil {
+			return replayErr
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d responses differ from the trace", failed, len(results))
+		}
+		return nil
+	},
+}
+
+// printReplayReport lists the results, grouped by connection if the trace
+// holds several.
+func printReplayReport(results []trace.Result, failed int, byConn bool) {
+	for i, res := range results {
+		if byConn && (i == 0 || res.Conn != results[i-1].Conn) {
+			fmt.Printf("Connection %d:\n", res.Conn)
+		}
+		switch res.Status {
+		case trace.StatusMatch:
+			fmt.Printf("[✓] %s (id %v) %.1fms\n", res.Method, res.ID, res.LatencyMS)
+		case trace.StatusSkipped:
+			fmt.Printf("[-] %s (id %v) skipped: no recorded response\n", res.Method, res.ID)
+		case trace.StatusError:
+			fmt.Printf("[✗] %s (id %v) failed: %s\n", res.Method, res.ID, res.Error)
+		case trace.StatusDiff:
+			fmt.Printf("[✗] %s (id %v) differs:\n", res.Method, res.ID)
+			for _, d := range res.Diffs {
+				fmt.Printf("      %s: expected %s, got %s\n", d.Path, d.Expected, d.Actual)
+			}
+		}
+	}
+	fmt.Printf("\nReplay Summary: %d requests replayed, %d matched, %d failed\n", len(results), len(results)-failed, failed)
+}
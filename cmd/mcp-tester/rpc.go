@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var rpcParams string
+
+func init() {
+	rpcCmd.Flags().StringVar(&rpcParams, "params", "", "Request params (JSON)")
+	rootCmd.AddCommand(rpcCmd)
+}
+
+// rpcCmd sends an arbitrary JSON-RPC request, e.g. completion/complete or a vendor-specific method.
+// The result, or the JSON-RPC error object with exit status 1, is printed as JSON.
+var rpcCmd = &cobra.Command{
+	Use:   "rpc <method>",
+	Short: "Send an arbitrary JSON-RPC request to the MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		method := args[0]
+		ctx := context.Background()
+
+		var params any
+		if rpcParams != "" {
+			if err := json.Unmarshal([]byte(rpcParams), &params); err != nil {
+				return fmt.Errorf("failed to parse params: %w", err)
+			}
+		}
+
+		config, err := loadConfig("mcp-tester.yml")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		settings, err := resolveSettings(config, profile, command, url, transportType)
+		if err != nil {
+			return err
+		}
+		transport, err := getTransport(ctx, settings)
+		if err != nil {
+			return err
+		}
+		rpc := newRaw(transport, os.Stdout)
+		session, err := getClient(verbose, os.Stdout).Connect(ctx, rpc, nil)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		defer session.Close()
+
+		result, err := rpc.Call(ctx, method, params)
+		if err != nil {
+			var rpcErr *client.RPCError
+			if errors.As(err, &rpcErr) {
+				// The error object is the output; it is not printed again.
+				output, _ := json.MarshalIndent(map[string]any{"code": rpcErr.Code, "message": rpcErr.Message, "data": rpcErr.Data}, "", "  ")
+				fmt.Println(string(output))
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &exitError{code: exitFailure}
+			}
+			return fmt.Errorf("%s failed: %w", method, err)
+		}
+
+		var output bytes.Buffer
+		if err := json.Indent(&output, result, "", "  "); err != nil {
+			return fmt.Errorf("invalid result: %w", err)
+		}
+		fmt.Println(output.String())
+		return nil
+	},
+}
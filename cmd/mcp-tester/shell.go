@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
+
+// shellMetaCommands are handled by the shell itself instead of the script runner.
+var shellMetaCommands = []string{":help", ":vars", ":last", ":tools", ":quit"}
+
+const shellHelp = `Script commands (call_tool, read_resource, rpc, set_var, assert_*, ...) run
+against the open session. Shell commands:
+  :vars   show script variables
+  :last   show the last response
+  :tools  reload the tool list used for completion
+  :quit   leave the shell (or Ctrl-D)
+`
+
+// shellCmd keeps one session open and executes script commands interactively.
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Open an interactive shell on a single MCP session",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig("mcp-tester.yml")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		settings, err := resolveSettings(config, profile, command, url, transportType)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		transport, err := getTransport(ctx, settings)
+		if err != nil {
+			return err
+		}
+		rpc := newRaw(transport, os.Stdout)
+		session, err := getClient(verbose, os.Stdout).Connect(ctx, rpc, nil)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		defer session.Close()
+
+		sh := &shell{
+			session: session,
+			runner:  scripting.NewRunner(session, rpc, raw),
+		}
+		sh.runner.ValidateInput = !noValidate
+		sh.interactive = scripting.NewInteractive(sh.runner)
+		defer func() {
+			if err := sh.interactive.Close(ctx); err != nil {
+				fmt.Printf("Teardown error: %v\n", err)
+			}
+		}()
+		sh.loadTools(ctx)
+		return sh.run(ctx)
+	},
+}
+
+type shell struct {
+	session     *mcp.ClientSession
+	runner      *scripting.Runner
+	interactive *scripting.Interactive
+	tools       []*mcp.Tool
+}
+
+// loadTools fetches the tool list for completion. Servers without tools simply
+// get no tool completion.
+func (sh *shell) loadTools(ctx context.Context) {
+	sh.tools = nil
+	for tool, err := range sh.session.Tools(ctx, nil) {
+		if err != nil {
+			return
+		}
+		sh.tools = append(sh.tools, tool)
+	}
+}
+
+func (sh *shell) run(ctx context.Context) error {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(sh.complete)
+
+	historyPath := shellHistoryPath()
+	if f, err := os.Open(historyPath); err == nil {
+		_, _ = line.ReadHistory(f)
+		f.Close()
+	}
+	defer func() {
+		if historyPath == "" {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(historyPath), 0700); err != nil {
+			return
+		}
+		if f, err := os.Create(historyPath); err == nil {
+			_, _ = line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	fmt.Printf("Connected. %d tools available. Type :help for help.\n", len(sh.tools))
+	for {
+		prompt := "mcp> "
+		if sh.interactive.Pending() {
+			prompt = "...> "
+		}
+		input, err := line.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(input) != "" {
+			line.AppendHistory(input)
+		}
+
+		if !sh.interactive.Pending() && strings.HasPrefix(strings.TrimSpace(input), ":") {
+			if quit := sh.meta(ctx, strings.TrimSpace(input)); quit {
+				return nil
+			}
+			continue
+		}
+		if _, err := sh.interactive.Exec(ctx, input); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// meta executes a shell command. It reports whether the shell should exit.
+func (sh *shell) meta(ctx context.Context, input string) bool {
+	switch input {
+	case ":quit", ":exit", ":q":
+		return true
+	case ":help":
+		fmt.Print(shellHelp)
+	case ":vars":
+		sh.runner.DumpVariables(os.Stdout)
+	case ":last":
+		if last := sh.runner.LastResponse(); last != "" {
+			fmt.Println(last)
+		} else {
+			fmt.Println("No response yet.")
+		}
+	case ":tools":
+		sh.loadTools(ctx)
+		fmt.Printf("%d tools loaded.\n", len(sh.tools))
+	default:
+		fmt.Printf("Unknown shell command %s. Type :help for help.\n", input)
+	}
+	return false
+}
+
+func (sh *shell) complete(line string, pos int) (string, []string, string) {
+	tail := line[pos:]
+	line = line[:pos]
+	if sh.interactive.InHeredoc() {
+		return line, nil, tail
+	}
+	if strings.HasPrefix(line, ":") && !strings.Contains(line, " ") {
+		var completions []string
+		for _, c := range shellMetaCommands {
+			if strings.HasPrefix(c, line) {
+				completions = append(completions, c)
+			}
+		}
+		return "", completions, tail
+	}
+	head, completions := scripting.Complete(line, sh.tools)
+	return head, completions, tail
+}
+
+// shellHistoryPath returns the history file in the user's cache directory, or
+// "" if there is none.
+func shellHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mcp-tester", "shell_history")
+}
@@ -1,53 +1,385 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 
+	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+	"github.com/hmsoft0815/mlc_mcptester/internal/report"
 	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
 	"github.com/spf13/cobra"
 )
 
-var scriptPath string
+var (
+	scriptPath      string
+	reports         []string
+	parallel        int
+	failFast        bool
+	maxFailures     int
+	validateOutput  bool
+	updateSnapshots bool
+
+	// scriptOutput receives the output of the scripts and the summary. It is
+	// stderr when a report is written to stdout, so that the report can be
+	// parsed.
+	scriptOutput io.Writer = os.Stdout
+)
 
 func init() {
 	testCmd.Flags().StringVarP(&scriptPath, "script", "s", "", "Path to the test script")
+	testCmd.Flags().StringArrayVar(&reports, "report", nil, "Write a report: junit[=file], tap[=file] or json[=file] (repeatable, stdout without file)")
+	testCmd.Flags().IntVar(&parallel, "parallel", 1, "Number of scripts to run at the same time")
+	testCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop at the first failure (same as --max-failures 1)")
+	testCmd.Flags().IntVar(&maxFailures, "max-failures", 0, "Stop after this many failed commands across all scripts (0 for no limit)")
+	testCmd.Flags().BoolVar(&validateOutput, "validate-output", false, "Check every tool result against the tool's outputSchema")
+	testCmd.Flags().BoolVar(&updateSnapshots, "update-snapshots", false, "Replace snapshots that do not match the response instead of failing")
 	rootCmd.AddCommand(testCmd)
 }
 
 var testCmd = &cobra.Command{
-	Use:   "test",
-	Short: "Run a test script against an MCP server",
+	Use:   "test [script|directory|glob]...",
+	Short: "Run test scripts against an MCP server",
+	Long: `Run test scripts against an MCP server.
+
+Scripts are given with --script or as arguments. A directory runs all *.mcp
+files directly inside it (subdirectories are not searched, so they can hold
+shared include files), a glob pattern all matching files. Every script gets its
+own session, and for stdio servers its own server process. With --parallel N up
+to N scripts run at the same time; their output is printed when they finish.
+
+--fail-fast and --max-failures stop the run early. Teardown sections still
+run, and scripts that were not started are reported as not run.
+
+Exit codes: 0 all passed, 1 assertion or command failures, 2 script syntax
+error, 3 connection failure. If several apply, the highest code is used.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if scriptPath == "" {
+		patterns := args
+		if scriptPath != "" {
+			patterns = append([]string{scriptPath}, patterns...)
+		}
+		if len(patterns) == 0 {
 			return fmt.Errorf("script path is required")
 		}
+		if parallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+		if maxFailures < 0 {
+			return fmt.Errorf("--max-failures must not be negative")
+		}
+		if failFast {
+			maxFailures = 1
+		}
+		scripts, err := discoverScripts(patterns)
+		if err != nil {
+			return err
+		}
+		var sinks []report.Spec
+		for _, r := range reports {
+			spec, err := report.ParseSpec(r)
+			if err != nil {
+				return err
+			}
+			sinks = append(sinks, spec)
+		}
+		toStdout := 0
+		for _, sink := range sinks {
+			if sink.Path == "" {
+				toStdout++
+			}
+		}
+		if toStdout > 1 {
+			return fmt.Errorf("only one --report can be written to stdout")
+		}
+		if toStdout == 1 {
+			scriptOutput = os.Stderr
+		}
 		config, err := loadConfig("mcp-tester.yml")
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
-		c, u, err := resolveSettings(config, profile, command, url)
+		settings, err := resolveSettings(config, profile, command, url, transportType)
 		if err != nil {
 			return err
 		}
-		script, err := os.ReadFile(scriptPath)
+
+		// From here on errors are test failures, not usage errors.
+		cmd.SilenceUsage = true
+		results, code := runScripts(context.Background(), settings, scripts)
+		for _, sink := range sinks {
+			if err := sink.Write(results); err != nil {
+				return err
+			}
+		}
+
+		failed := 0
+		for _, r := range results {
+			if !r.OK() {
+				failed++
+			}
+		}
+		if len(scripts) > 1 {
+			printScriptSummary(results, failed, len(scripts)-len(results))
+		}
+		if code != exitOK {
+			return &exitError{code: code, err: fmt.Errorf("%d of %d scripts failed", failed, len(scripts))}
+		}
+		return nil
+	},
+}
+
+// discoverScripts expands directories and glob patterns into a sorted list of
+// script files without duplicates.
+func discoverScripts(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var scripts []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			scripts = append(scripts, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if info, err := os.Stat(pattern); err == nil {
+			if !info.IsDir() {
+				add(pattern)
+				continue
+			}
+			matches, err := filepath.Glob(filepath.Join(pattern, "*.mcp"))
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no *.mcp scripts found in %s", pattern)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
 		if err != nil {
-			return fmt.Errorf("failed to read script: %w", err)
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no scripts match %s", pattern)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				add(m)
+			}
+		}
+	}
+	return scripts, nil
+}
+
+// failureBudget tracks the failures allowed by --max-failures across all
+// scripts. A limit of 0 allows any number. Failed commands are counted as
+// they happen, so that scripts running in parallel stop as soon as the
+// limit is reached by any of them.
+type failureBudget struct {
+	mu     sync.Mutex
+	limit  int
+	failed int
+}
+
+// left reports whether the limit is not used up yet.
+func (b *failureBudget) left() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit == 0 || b.failed < b.limit
+}
+
+// command counts a command of a running script and reports whether the
+// script may continue.
+func (b *failureBudget) command(failed bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failed {
+		b.failed++
+	}
+	return b.limit == 0 || b.failed < b.limit
+}
+
+// add counts a script that could not be run at all.
+func (b *failureBudget) add(r *scripting.TestResult) {
+	if r.Error == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed++
+}
+
+// runScripts runs every script on its own session, up to parallel at a time,
+// and returns the results of the scripts that were started together with the
+// exit code. Sequential runs stream their output; with --parallel each
+// script's output is printed as a block once the script has finished.
+func runScripts(ctx context.Context, settings Profile, scripts []string) ([]*scripting.TestResult, int) {
+	results := make([]*scripting.TestResult, len(scripts))
+	codes := make([]int, len(scripts))
+	budget := &failureBudget{limit: maxFailures}
+
+	// With several scripts the combined summary replaces the per-script JSON
+	// documents.
+	scriptFormat := format
+	if format == "json" && len(scripts) > 1 {
+		scriptFormat = ""
+	}
+	header := format == "text" && len(scripts) > 1
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, script := range scripts {
+		sem <- struct{}{}
+		if !budget.left() {
+			<-sem
+			break
+		}
+		run := func(out io.Writer) {
+			results[i], codes[i] = runScript(ctx, settings, script, scriptFormat, budget, out)
+			budget.add(results[i])
+		}
+
+		if parallel == 1 {
+			if header {
+				fmt.Fprintf(scriptOutput, "=== %s\n", script)
+			}
+			run(scriptOutput)
+			if header {
+				fmt.Fprintln(scriptOutput)
+			}
+			<-sem
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			var out bytes.Buffer
+			run(&syncWriter{w: &out})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if header {
+				fmt.Fprintf(scriptOutput, "=== %s\n", script)
+			}
+			_, _ = out.WriteTo(scriptOutput)
+			if header {
+				fmt.Fprintln(scriptOutput)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var started []*scripting.TestResult
+	code := exitOK
+	for i, r := range results {
+		if r != nil {
+			started = append(started, r)
+			code = max(code, codes[i])
 		}
-		ctx := context.Background()
-		transport, err := getTransport(ctx, c, u)
+	}
+	return started, code
+}
+
+// syncWriter serializes writes to w. Server notifications are printed from
+// the session's goroutines while the script writes its own output.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// runScript connects a new session and runs one script with the given failure
+// budget. Failures to connect or parse are returned as the result's Error. The
+// exit code classifies the outcome.
+func runScript(ctx context.Context, settings Profile, path, outputFormat string, budget *failureBudget, out io.Writer) (*scripting.TestResult, int) {
+	code := exitConnection
+	result, err := func() (*scripting.TestResult, error) {
+		transport, err := getTransport(ctx, settings)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		client := getClient(verbose)
-		session, err := client.Connect(ctx, transport, nil)
+		// Notifications go to the script's own output, so that they are part
+		// of its block under --parallel.
+		rpc := newRaw(transport, out)
+		session, err := getClient(verbose, out).Connect(ctx, rpc, nil)
 		if err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
+			return nil, fmt.Errorf("failed to connect: %w", err)
 		}
 		defer session.Close()
-		runner := scripting.NewRunner(session, raw)
-		_, err = runner.Run(ctx, string(script), format)
-		return err
-	},
+		runner := scripting.NewRunner(session, rpc, raw)
+		runner.Output = out
+		runner.OnCommand = budget.command
+		runner.ValidateInput = !noValidate
+		runner.ValidateOutput = validateOutput
+		runner.UpdateSnapshots = updateSnapshots
+		code = exitFailure
+		return runner.RunFile(ctx, path, outputFormat)
+	}()
+	if err != nil {
+		var syntaxErr *scripting.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			code = exitSyntax
+		}
+		if outputFormat == "text" {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+		return &scripting.TestResult{Script: path, Error: err.Error()}, code
+	}
+	if result.OK() {
+		return result, exitOK
+	}
+	return result, exitFailure
+}
+
+func printScriptSummary(results []*scripting.TestResult, failed, notRun int) {
+	if format == "json" {
+		out, _ := json.MarshalIndent(map[string]any{
+			"scripts": len(results) + notRun,
+			"failed":  failed,
+			"not_run": notRun,
+			"results": results,
+		}, "", "  ")
+		fmt.Fprintln(scriptOutput, string(out))
+		return
+	}
+
+	var executed, passed, failedCmds int
+	for _, r := range results {
+		status := "ok  "
+		switch {
+		case !r.OK():
+			status = "FAIL"
+		case r.Stopped:
+			// Stopped by the failures of other scripts.
+			status = "stop"
+		}
+		fmt.Fprintf(scriptOutput, "%s %s\n", status, r.Script)
+		executed += r.Executed
+		passed += r.Passed
+		failedCmds += r.Failed
+	}
+	fmt.Fprint(scriptOutput, i18n.T(i18n.MsgScriptSummary, len(results), len(results)-failed, failed, executed, passed, failedCmds))
+	if notRun > 0 {
+		fmt.Fprint(scriptOutput, i18n.T(i18n.MsgScriptsNotRun, notRun))
+	}
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverScripts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"02_b.mcp", "01_a.mcp", "notes.txt", "lib/common.mcp", "other/03_c.mcp"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("ping\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		patterns []string
+		want     []string
+		wantErr  bool
+	}{
+		{[]string{dir}, []string{p("01_a.mcp"), p("02_b.mcp")}, false},
+		{[]string{p("*/*.mcp")}, []string{p("lib/common.mcp"), p("other/03_c.mcp")}, false},
+		{[]string{p("02_b.mcp"), dir}, []string{p("02_b.mcp"), p("01_a.mcp")}, false},
+		{[]string{p("notes.txt")}, []string{p("notes.txt")}, false},
+		{[]string{p("missing*.mcp")}, nil, true},
+		{[]string{p("empty")}, nil, true},
+	}
+	if err := os.Mkdir(p("empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got, err := discoverScripts(tt.patterns)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("discoverScripts(%q) = %q, %v; want %q", tt.patterns, got, err, tt.want)
+		}
+	}
+}
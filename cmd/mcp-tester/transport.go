@@ -2,25 +2,32 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
+	"sync"
 
+	"github.com/hmsoft0815/mlc_mcptester/internal/client"
+	"github.com/hmsoft0815/mlc_mcptester/internal/trace"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-// getClient returns a new MCP client with optional logging and notification handlers.
-func getClient(verbose bool) *mcp.Client {
+// getClient returns a new MCP client with optional logging and notification
+// handlers. Server log messages and progress notifications are printed to out.
+func getClient(verbose bool, out io.Writer) *mcp.Client {
 	opts := &mcp.ClientOptions{
 		// sometimes..
 		// Handler for logging notifications from the server
 		LoggingMessageHandler: func(ctx context.Context, req *mcp.LoggingMessageRequest) {
-			fmt.Printf("[SERVER LOG] [%s] %s: %v\n", req.Params.Level, req.Params.Logger, req.Params.Data)
+			printLogMessage(out, req.Params)
 		},
 		// Handler for progress notifications from the server
 		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
-			fmt.Printf("[PROGRESS] Token: %v, Done: %.2f, Total: %.2f, Msg: %s\n", req.Params.ProgressToken, req.Params.Progress, req.Params.Total, req.Params.Message)
+			printProgress(out, req.Params)
 		},
 	}
 	if verbose {
@@ -36,22 +43,180 @@ func getClient(verbose bool) *mcp.Client {
 	)
 }
 
-// getTransport returns the appropriate MCP transport based on the provided command or URL.
-// It supports CommandTransport for local execution and SSEClientTransport for remote URLs.
-func getTransport(ctx context.Context, command, url string) (mcp.Transport, error) {
-	// If a command is provided, use stdio transport via shell execution.
-	// hmm - win/mac ?
-	if command != "" {
-		return &mcp.CommandTransport{
-			Command: exec.CommandContext(ctx, "sh", "-c", command),
-		}, nil
+func printLogMessage(out io.Writer, p *mcp.LoggingMessageParams) {
+	fmt.Fprintf(out, "[SERVER LOG] [%s] %s: %v\n", p.Level, p.Logger, p.Data)
+}
+
+func printProgress(out io.Writer, p *mcp.ProgressNotificationParams) {
+	fmt.Fprintf(out, "[PROGRESS] Token: %v, Done: %.2f, Total: %.2f, Msg: %s\n", p.ProgressToken, p.Progress, p.Total, p.Message)
+}
+
+// newRaw wraps transport for a session that also sends raw requests. Server
+// notifications that arrive with raw calls are printed to out like those of
+// the session.
+func newRaw(transport mcp.Transport, out io.Writer) *client.Raw {
+	rpc := client.NewRaw(transport)
+	rpc.OnNotification = func(method string, params json.RawMessage) {
+		switch method {
+		case "notifications/message":
+			var p mcp.LoggingMessageParams
+			if json.Unmarshal(params, &p) == nil {
+				printLogMessage(out, &p)
+			}
+		case "notifications/progress":
+			var p mcp.ProgressNotificationParams
+			if json.Unmarshal(params, &p) == nil {
+				printProgress(out, &p)
+			}
+		}
+	}
+	return rpc
+}
+
+// Supported values for the --transport flag and the profile's transport key.
+const (
+	transportStdio      = "stdio"
+	transportSSE        = "sse"
+	transportStreamable = "http"
+)
+
+// transportKind determines which transport a profile uses. Without an explicit
+// transport, a command selects stdio and a URL selects SSE.
+func transportKind(p Profile) (string, error) {
+	switch p.Transport {
+	case "":
+		if p.Command != "" {
+			return transportStdio, nil
+		}
+		if p.URL != "" {
+			return transportSSE, nil
+		}
+		return "", fmt.Errorf("either --command or --url is required")
+	case transportStdio, transportSSE:
+		return p.Transport, nil
+	case transportStreamable, "streamable":
+		return transportStreamable, nil
+	default:
+		return "", fmt.Errorf("unknown transport %q (expected stdio, sse or http)", p.Transport)
 	}
-	// If a URL is provided, use SSE (Server-Sent Events) transport.
-	if url != "" {
+}
+
+// getTransport returns the appropriate MCP transport for the resolved profile,
+// recording its messages if --trace is set.
+func getTransport(ctx context.Context, p Profile) (mcp.Transport, error) {
+	var w *trace.Writer
+	if traceFile != "" {
+		var err error
+		if w, err = openTrace(); err != nil {
+			return nil, err
+		}
+	}
+	return newTransport(ctx, p, w)
+}
+
+var (
+	traceOnce   sync.Once
+	traceWriter *trace.Writer
+	traceErr    error
+)
+
+// openTrace creates the --trace file once; all connections of the process share it.
+// closeTrace closes it when the command is done.
+func openTrace() (*trace.Writer, error) {
+	traceOnce.Do(func() {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			traceErr = fmt.Errorf("failed to create trace file: %w", err)
+			return
+		}
+		traceWriter = trace.NewWriter(f)
+	})
+	return traceWriter, traceErr
+}
+
+func closeTrace() {
+	if traceWriter != nil {
+		if err := traceWriter.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write trace file: %v\n", err)
+		}
+	}
+}
+
+// newTransport creates the transport for the resolved profile. It supports
+// CommandTransport for local execution, SSEClientTransport and
+// StreamableClientTransport for remote URLs. If tw is not nil, the messages
+// are recorded to it.
+func newTransport(ctx context.Context, p Profile, tw *trace.Writer) (mcp.Transport, error) {
+	kind, err := transportKind(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == transportStdio {
+		cmd, err := buildCommand(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if tw != nil {
+			return &trace.CommandTransport{Command: cmd, Trace: tw}, nil
+		}
+		return &mcp.CommandTransport{Command: cmd}, nil
+	}
+
+	if p.URL == "" {
+		return nil, fmt.Errorf("transport %q requires --url", kind)
+	}
+	httpClient, err := newHTTPClient(p)
+	if err != nil {
+		return nil, err
+	}
+	if tw != nil {
+		// One round tripper per transport, so its requests form one connection.
+		if httpClient == nil {
+			httpClient = &http.Client{}
+		}
+		httpClient.Transport = trace.NewRoundTripper(httpClient.Transport, tw)
+	}
+	if kind == transportSSE {
+		// Use SSE (Server-Sent Events) transport.
 		return &mcp.SSEClientTransport{
-			Endpoint: url,
+			Endpoint:   p.URL,
+			HTTPClient: httpClient,
 		}, nil
 	}
-	// Return an error if neither transport configuration is provided.
-	return nil, fmt.Errorf("either --command or --url is required")
+	// Use the Streamable HTTP transport.
+	return &mcp.StreamableClientTransport{
+		Endpoint:   p.URL,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// buildCommand prepares the exec.Cmd for a stdio server, applying the
+// profile's arguments, environment and working directory.
+func buildCommand(ctx context.Context, p Profile) (*exec.Cmd, error) {
+	if p.Command == "" {
+		return nil, fmt.Errorf("transport %q requires --command", transportStdio)
+	}
+
+	var cmd *exec.Cmd
+	if len(p.Args) > 0 {
+		// An explicit argument list is executed directly, without a shell.
+		cmd = exec.CommandContext(ctx, p.Command, p.Args...)
+	} else {
+		// Use stdio transport via shell execution.
+		// hmm - win/mac ?
+		cmd = exec.CommandContext(ctx, "sh", "-c", p.Command)
+	}
+
+	if p.Env != nil || p.EnvFile != "" {
+		env, err := processEnv(p)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = env
+	}
+	if p.Cwd != "" {
+		cmd.Dir = workDir(p)
+	}
+	return cmd, nil
 }
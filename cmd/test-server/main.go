@@ -9,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/hmsoft0815/mlc_mcptester/internal/auth"
 	"github.com/hmsoft0815/mlc_mcptester/internal/version"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -19,6 +20,11 @@ const serverIcon = "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My
 func main() {
 	showVersion := flag.Bool("version", false, "print the version and exit")
 	addr := flag.String("addr", "", "Listen address for SSE (e.g. \":8080\"). If empty, uses stdio.")
+	httpAddr := flag.String("http", "", "Listen address for Streamable HTTP (e.g. \":8082\"). If empty, uses stdio.")
+	requireAuth := flag.Bool("require-auth", false, "Protect the SSE/HTTP endpoint with OAuth using a built-in token issuer")
+	clientID := flag.String("client-id", "mcp-tester", "Pre-registered client ID for the built-in issuer")
+	clientSecret := flag.String("client-secret", "secret", "Secret of the pre-registered client")
+	tokenTTL := flag.Duration("token-ttl", time.Hour, "Lifetime of access tokens issued with --require-auth")
 	flag.Parse()
 
 	if *showVersion {
@@ -46,10 +52,30 @@ func main() {
 	registerResources(s)
 	registerPrompts(s)
 
-	if *addr != "" {
+	// protect wraps remote handlers with the built-in OAuth issuer if requested.
+	protect := func(h http.Handler) http.Handler {
+		if !*requireAuth {
+			return h
+		}
+		issuer := auth.NewIssuer(*tokenTTL)
+		issuer.AddClient(*clientID, *clientSecret)
+		fmt.Fprintf(os.Stderr, "OAuth required (client %q, token TTL %s)\n", *clientID, *tokenTTL)
+		return issuer.Handler(h)
+	}
+	if *requireAuth && *httpAddr == "" && *addr == "" {
+		log.Fatal("--require-auth needs -http or -addr")
+	}
+
+	if *httpAddr != "" {
+		fmt.Fprintf(os.Stderr, "Starting Ultimate Test Server on Streamable HTTP (%s)...\n", *httpAddr)
+		handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return s }, nil)
+		if err := http.ListenAndServe(*httpAddr, protect(handler)); err != nil {
+			log.Fatalf("Streamable HTTP server failed: %v", err)
+		}
+	} else if *addr != "" {
 		fmt.Fprintf(os.Stderr, "Starting Ultimate Test Server on SSE (%s)...\n", *addr)
 		handler := mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return s }, nil)
-		if err := http.ListenAndServe(*addr, handler); err != nil {
+		if err := http.ListenAndServe(*addr, protect(handler)); err != nil {
 			log.Fatalf("SSE server failed: %v", err)
 		}
 	} else {
@@ -0,0 +1,132 @@
+// Package auth implements the OAuth 2.1 authorization flow for protected MCP servers.
+//
+// A Transport wraps the HTTP transport used by the SSE and Streamable HTTP clients.
+// When the server answers 401, it discovers the authorization server through the
+// protected resource metadata, obtains a token with the configured grant and
+// retries the request. Tokens are cached per profile and refreshed automatically.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Supported grant types.
+const (
+	FlowClientCredentials = "client_credentials"
+	FlowAuthorizationCode = "authorization_code"
+)
+
+// Config describes how mcp-tester obtains access tokens for a profile.
+type Config struct {
+	// Flow is either "client_credentials" or "authorization_code" (default).
+	Flow string `yaml:"flow,omitempty"`
+	// ClientID of a pre-registered client. If empty, the authorization code flow
+	// falls back to dynamic client registration.
+	ClientID     string `yaml:"clientId,omitempty"`
+	ClientSecret string `yaml:"clientSecret,omitempty"`
+	// Scopes to request. Defaults to the scopes announced by the server.
+	Scopes []string `yaml:"scopes,omitempty"`
+	// RedirectPort is the loopback port for the authorization code redirect.
+	// Zero picks a free port, which only works with dynamic client registration.
+	RedirectPort int `yaml:"redirectPort,omitempty"`
+	// AutoApprove follows the authorization URL directly instead of opening a
+	// browser. Only useful for issuers that approve without user interaction,
+	// such as test-server --require-auth.
+	AutoApprove bool `yaml:"autoApprove,omitempty"`
+}
+
+// flow returns the configured grant type, defaulting to the authorization code flow.
+func (c *Config) flow() (string, error) {
+	switch c.Flow {
+	case "", FlowAuthorizationCode:
+		return FlowAuthorizationCode, nil
+	case FlowClientCredentials:
+		if c.ClientID == "" {
+			return "", fmt.Errorf("oauth flow %q requires a clientId", c.Flow)
+		}
+		return FlowClientCredentials, nil
+	default:
+		return "", fmt.Errorf("unknown oauth flow %q (expected client_credentials or authorization_code)", c.Flow)
+	}
+}
+
+// Entry is a cached token together with everything needed to refresh it.
+type Entry struct {
+	Resource     string        `json:"resource"`
+	Flow         string        `json:"flow"`
+	TokenURL     string        `json:"tokenUrl"`
+	ClientID     string        `json:"clientId"`
+	ClientSecret string        `json:"clientSecret,omitempty"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	Token        *oauth2.Token `json:"token"`
+}
+
+// Store persists token entries as one JSON file per key in a directory.
+type Store struct {
+	Dir string
+}
+
+// DefaultStore returns the token store in the user's cache directory.
+func DefaultStore() (*Store, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: filepath.Join(dir, "mcp-tester", "tokens")}, nil
+}
+
+func (s *Store) path(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return filepath.Join(s.Dir, b.String()+".json")
+}
+
+// Load returns the cached entry for key, or nil if there is none.
+func (s *Store) Load(key string) (*Entry, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("corrupt token cache %s: %w", s.path(key), err)
+	}
+	return &e, nil
+}
+
+// Save writes the entry for key. The file is only readable by the current user.
+func (s *Store) Save(key string, e *Entry) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(key), data, 0600)
+}
+
+// Delete removes the entry for key. Deleting a missing entry is not an error.
+func (s *Store) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
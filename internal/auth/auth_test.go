@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newProtectedServer starts a resource server protected by a local Issuer.
+// It returns the MCP endpoint URL and a counter of unauthorized responses.
+func newProtectedServer(t *testing.T, ttl time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+	issuer := NewIssuer(ttl)
+	issuer.AddClient("tester", "secret")
+	unauthorized := &atomic.Int32{}
+	resource := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("ok:" + string(body)))
+	})
+	handler := issuer.Handler(resource)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code == http.StatusUnauthorized && r.URL.Path == "/mcp" {
+			unauthorized.Add(1)
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/mcp", unauthorized
+}
+
+func post(t *testing.T, client *http.Client, url, body string) string {
+	t.Helper()
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s = %d %s", url, resp.StatusCode, data)
+	}
+	return string(data)
+}
+
+func TestClientCredentialsFlow(t *testing.T) {
+	endpoint, unauthorized := newProtectedServer(t, time.Hour)
+	store := &Store{Dir: t.TempDir()}
+	cfg := &Config{Flow: FlowClientCredentials, ClientID: "tester", ClientSecret: "secret"}
+
+	client := &http.Client{Transport: NewTransport(nil, endpoint, cfg, store, "cc")}
+	if got := post(t, client, endpoint, `{"n":1}`); got != `ok:{"n":1}` {
+		t.Errorf("response = %q; body was not replayed after authorization", got)
+	}
+	post(t, client, endpoint, `{"n":2}`)
+	if n := unauthorized.Load(); n != 1 {
+		t.Errorf("expected exactly one 401, got %d", n)
+	}
+
+	entry, err := store.Load("cc")
+	if err != nil || entry == nil || entry.Token.AccessToken == "" {
+		t.Fatalf("token not cached: (%+v, %v)", entry, err)
+	}
+	if entry.ClientSecret != "" {
+		t.Error("configured client secret must not be written to the cache")
+	}
+
+	// A new transport reuses the cached token without another 401.
+	client = &http.Client{Transport: NewTransport(nil, endpoint, cfg, store, "cc")}
+	post(t, client, endpoint, `{}`)
+	if n := unauthorized.Load(); n != 1 {
+		t.Errorf("cached token was not reused, got %d 401s", n)
+	}
+
+	// Wrong credentials surface as an error.
+	bad := &Config{Flow: FlowClientCredentials, ClientID: "tester", ClientSecret: "wrong"}
+	client = &http.Client{Transport: NewTransport(nil, endpoint, bad, nil, "")}
+	if _, err := client.Post(endpoint, "application/json", strings.NewReader("{}")); err == nil {
+		t.Error("expected error for wrong client secret")
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	// The oauth2 package treats tokens expiring within 10s as expired, so every
+	// request after the first triggers a refresh.
+	endpoint, unauthorized := newProtectedServer(t, 5*time.Second)
+	store := &Store{Dir: t.TempDir()}
+	cfg := &Config{AutoApprove: true}
+
+	transport := NewTransport(nil, endpoint, cfg, store, "code")
+	transport.OpenURL = func(context.Context, string) error {
+		t.Error("browser must not be opened with autoApprove")
+		return nil
+	}
+	client := &http.Client{Transport: transport}
+	post(t, client, endpoint, `{}`)
+
+	first, _ := store.Load("code")
+	if first == nil || first.ClientID == "" || first.Token.RefreshToken == "" {
+		t.Fatalf("expected registered client and refresh token in cache, got %+v", first)
+	}
+
+	post(t, client, endpoint, `{}`)
+	second, _ := store.Load("code")
+	if second.Token.AccessToken == first.Token.AccessToken {
+		t.Error("expected the refreshed token to be written to the cache")
+	}
+	if n := unauthorized.Load(); n != 1 {
+		t.Errorf("expected a single authorization, got %d 401s", n)
+	}
+}
+
+func TestConcurrentAuthorization(t *testing.T) {
+	endpoint, unauthorized := newProtectedServer(t, time.Hour)
+	transport := NewTransport(nil, endpoint, &Config{}, nil, "")
+	var opened atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	transport.OpenURL = func(_ context.Context, url string) error {
+		if opened.Add(1) == 1 {
+			close(started)
+		}
+		// The user approves in the browser once release is closed.
+		go func() {
+			<-release
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	client := &http.Client{Transport: transport}
+
+	errs := make(chan error, 2)
+	request := func() {
+		resp, err := client.Post(endpoint, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+		errs <- err
+	}
+	go request()
+	<-started
+	// A request while the first one waits for the browser is not blocked, and
+	// waits for that authorization instead of starting its own.
+	go request()
+	deadline := time.Now().Add(5 * time.Second)
+	for unauthorized.Load() < 2 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("the second request did not get through while authorizing, %d 401s", unauthorized.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := opened.Load(); n != 1 {
+		t.Errorf("the browser was opened %d times, want 1", n)
+	}
+}
+
+func TestDiscoverWithoutChallenge(t *testing.T) {
+	endpoint, _ := newProtectedServer(t, time.Hour)
+	md, err := Discover(context.Background(), http.DefaultClient, endpoint, http.Header{})
+	if err != nil {
+		t.Fatalf("Discover error = %v", err)
+	}
+	if !strings.HasSuffix(md.TokenEndpoint, "/token") || md.RegistrationEndpoint == "" {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+	if len(md.Scopes) != 1 || md.Scopes[0] != "mcp" {
+		t.Errorf("scopes = %v; want [mcp]", md.Scopes)
+	}
+
+	if _, err := Discover(context.Background(), http.DefaultClient, "http://example.invalid/mcp", http.Header{}); err == nil {
+		t.Error("expected error for metadata without HTTPS on a remote host")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	params := parseChallenge([]string{`Basic realm="x", Bearer resource_metadata="https://a.example/.well-known/oauth-protected-resource", scope="read write", error=invalid_token`})
+	if params["resource_metadata"] != "https://a.example/.well-known/oauth-protected-resource" {
+		t.Errorf("resource_metadata = %q", params["resource_metadata"])
+	}
+	if params["scope"] != "read write" || params["error"] != "invalid_token" {
+		t.Errorf("unexpected params: %v", params)
+	}
+	if _, ok := params["realm"]; ok {
+		t.Error("parameters of non-Bearer challenges must be ignored")
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// resourceMetadata is the subset of RFC 9728 protected resource metadata used here.
+type resourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers,omitempty"`
+	ScopesSupported      []string `json:"scopes_supported,omitempty"`
+	ResourceName         string   `json:"resource_name,omitempty"`
+}
+
+// serverMetadata is the subset of RFC 8414 authorization server metadata used here.
+type serverMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	RegistrationEndpoint              string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// Metadata is the result of authorization server discovery for a resource.
+type Metadata struct {
+	Resource              string
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	RegistrationEndpoint  string
+	// Scopes announced by the server, either in the WWW-Authenticate challenge
+	// or in the protected resource metadata.
+	Scopes []string
+}
+
+// challengeParam matches auth-param pairs such as resource_metadata="https://..." or scope=mcp.
+var challengeParam = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*(?:"((?:[^"\\]|\\.)*)"|([^\s,]*))`)
+
+// parseChallenge returns the auth-params of the Bearer challenges in the
+// WWW-Authenticate headers. The first occurrence of a parameter wins.
+func parseChallenge(values []string) map[string]string {
+	params := make(map[string]string)
+	for _, v := range values {
+		idx := strings.Index(strings.ToLower(v), "bearer")
+		if idx == -1 {
+			continue
+		}
+		for _, m := range challengeParam.FindAllStringSubmatch(v[idx+len("bearer"):], -1) {
+			key := strings.ToLower(m[1])
+			if _, seen := params[key]; seen {
+				continue
+			}
+			val := m[3]
+			if m[2] != "" {
+				val = strings.ReplaceAll(m[2], `\"`, `"`)
+			}
+			params[key] = val
+		}
+	}
+	return params
+}
+
+// Discover finds the authorization server for resourceURL (RFC 9728, RFC 8414).
+// The header of the 401 response is used to locate the protected resource
+// metadata; without a resource_metadata parameter the well-known locations are tried.
+func Discover(ctx context.Context, client *http.Client, resourceURL string, header http.Header) (*Metadata, error) {
+	challenge := parseChallenge(header.Values("WWW-Authenticate"))
+
+	var metadataURLs []string
+	if u := challenge["resource_metadata"]; u != "" {
+		metadataURLs = append(metadataURLs, u)
+	}
+	wellKnown, err := wellKnownURLs(resourceURL, "oauth-protected-resource")
+	if err != nil {
+		return nil, err
+	}
+	metadataURLs = append(metadataURLs, wellKnown...)
+
+	var prm *resourceMetadata
+	var lastErr error
+	for _, u := range metadataURLs {
+		prm, lastErr = fetchMetadata[resourceMetadata](ctx, client, u)
+		if lastErr == nil && prm.Resource != resourceURL {
+			// RFC 9728 §3.3: the metadata must describe the resource that was requested.
+			lastErr = fmt.Errorf("metadata at %s is for resource %q, want %q", u, prm.Resource, resourceURL)
+			prm = nil
+		}
+		if prm != nil {
+			break
+		}
+	}
+	if prm == nil {
+		return nil, fmt.Errorf("no protected resource metadata found for %s: %w", resourceURL, lastErr)
+	}
+	if len(prm.AuthorizationServers) == 0 {
+		return nil, fmt.Errorf("protected resource metadata for %s lists no authorization servers", resourceURL)
+	}
+
+	asm, err := getServerMetadata(ctx, client, prm.AuthorizationServers[0])
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(challenge["scope"])
+	if len(scopes) == 0 {
+		scopes = prm.ScopesSupported
+	}
+	return &Metadata{
+		Resource:              resourceURL,
+		Issuer:                asm.Issuer,
+		AuthorizationEndpoint: asm.AuthorizationEndpoint,
+		TokenEndpoint:         asm.TokenEndpoint,
+		RegistrationEndpoint:  asm.RegistrationEndpoint,
+		Scopes:                scopes,
+	}, nil
+}
+
+// getServerMetadata tries the OAuth and OpenID Connect well-known locations of the issuer.
+func getServerMetadata(ctx context.Context, client *http.Client, issuer string) (*serverMetadata, error) {
+	oauthURLs, err := wellKnownURLs(issuer, "oauth-authorization-server")
+	if err != nil {
+		return nil, err
+	}
+	oidcURLs, err := wellKnownURLs(issuer, "openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	oidcURLs = append(oidcURLs, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+
+	var lastErr error
+	for _, u := range append(oauthURLs, oidcURLs...) {
+		asm, err := fetchMetadata[serverMetadata](ctx, client, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// RFC 8414 §3.3: the issuer must match the URL the metadata was derived from.
+		if asm.Issuer != issuer {
+			return nil, fmt.Errorf("metadata issuer %q does not match %q", asm.Issuer, issuer)
+		}
+		if asm.TokenEndpoint == "" {
+			return nil, fmt.Errorf("authorization server %s has no token endpoint", issuer)
+		}
+		return asm, nil
+	}
+	return nil, fmt.Errorf("no authorization server metadata found for issuer %s: %w", issuer, lastErr)
+}
+
+// fetchMetadata loads a JSON metadata document. Plain HTTP is only accepted for
+// loopback hosts, to allow local test servers.
+func fetchMetadata[T any](ctx context.Context, client *http.Client, rawURL string) (*T, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && !isLoopback(u.Hostname()) {
+		return nil, fmt.Errorf("metadata URL %s does not use HTTPS", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	var v T
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&v); err != nil {
+		return nil, fmt.Errorf("GET %s: invalid metadata: %w", rawURL, err)
+	}
+	return &v, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// wellKnownURLs returns the well-known URLs for rawURL, first with the path
+// appended (RFC 8414 §3.1) and then at the root of the origin.
+func wellKnownURLs(rawURL, name string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	origin := u.Scheme + "://" + u.Host
+	root := origin + "/.well-known/" + name
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "" {
+		return []string{root}, nil
+	}
+	return []string{root + path, root}, nil
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// authorizationTimeout limits how long the loopback listener waits for the browser redirect.
+const authorizationTimeout = 5 * time.Minute
+
+// clientCredentialsToken obtains a token using the client credentials grant.
+func clientCredentialsToken(ctx context.Context, e *Entry) (*oauth2.Token, error) {
+	cfg := clientCredentialsConfig(e)
+	tok, err := cfg.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client credentials grant failed: %w", err)
+	}
+	return tok, nil
+}
+
+func clientCredentialsConfig(e *Entry) *clientcredentials.Config {
+	return &clientcredentials.Config{
+		ClientID:       e.ClientID,
+		ClientSecret:   e.ClientSecret,
+		TokenURL:       e.TokenURL,
+		Scopes:         e.Scopes,
+		EndpointParams: url.Values{"resource": {e.Resource}},
+	}
+}
+
+func authCodeConfig(e *Entry, authURL, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     e.ClientID,
+		ClientSecret: e.ClientSecret,
+		Endpoint:     oauth2.Endpoint{AuthURL: authURL, TokenURL: e.TokenURL},
+		RedirectURL:  redirectURL,
+		Scopes:       e.Scopes,
+	}
+}
+
+// callbackResult carries the parameters of the authorization redirect.
+type callbackResult struct {
+	code  string
+	state string
+	err   error
+}
+
+// authorizationCodeToken runs the authorization code flow with PKCE. It listens
+// on a loopback address for the redirect, registers a client dynamically if no
+// client ID is configured, and exchanges the code for a token.
+func authorizationCodeToken(ctx context.Context, client *http.Client, cfg *Config, md *Metadata, e *Entry, openURL func(context.Context, string) error) (*oauth2.Token, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", cfg.RedirectPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the authorization redirect: %w", err)
+	}
+	defer ln.Close()
+	redirectURL := fmt.Sprintf("http://%s/callback", ln.Addr().String())
+
+	if e.ClientID == "" {
+		if err := registerClient(ctx, client, md, e, redirectURL); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make(chan callbackResult, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		res := callbackResult{code: q.Get("code"), state: q.Get("state")}
+		if msg := q.Get("error"); msg != "" {
+			res.err = fmt.Errorf("authorization denied: %s %s", msg, q.Get("error_description"))
+			http.Error(w, "Authorization failed. You can close this window.", http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Authorization complete. You can close this window and return to mcp-tester.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	oauthCfg := authCodeConfig(e, md.AuthorizationEndpoint, redirectURL)
+	verifier := oauth2.GenerateVerifier()
+	state := rand.Text()
+	authURL := oauthCfg.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("resource", e.Resource),
+	)
+
+	waitCtx, cancel := context.WithTimeout(ctx, authorizationTimeout)
+	defer cancel()
+
+	if cfg.AutoApprove {
+		go followAuthorizationURL(waitCtx, client, authURL, results)
+	} else if err := openURL(waitCtx, authURL); err != nil {
+		return nil, err
+	}
+
+	var res callbackResult
+	select {
+	case res = <-results:
+	case <-waitCtx.Done():
+		return nil, fmt.Errorf("timed out waiting for the authorization redirect: %w", waitCtx.Err())
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	if res.state != state {
+		return nil, errors.New("authorization redirect has a mismatching state")
+	}
+
+	tok, err := oauthCfg.Exchange(ctx, res.code,
+		oauth2.VerifierOption(verifier),
+		oauth2.SetAuthURLParam("resource", e.Resource),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	return tok, nil
+}
+
+// clientRegistration is the subset of RFC 7591 client metadata used here.
+type clientRegistration struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientID                string   `json:"client_id,omitempty"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+}
+
+// registerClient performs dynamic client registration (RFC 7591) for a public client.
+func registerClient(ctx context.Context, client *http.Client, md *Metadata, e *Entry, redirectURL string) error {
+	if md.RegistrationEndpoint == "" {
+		return fmt.Errorf("no clientId configured and %s does not support dynamic client registration", md.Issuer)
+	}
+	body, err := json.Marshal(&clientRegistration{
+		RedirectURIs:            []string{redirectURL},
+		TokenEndpointAuthMethod: "none",
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		ResponseTypes:           []string{"code"},
+		ClientName:              "mcp-tester",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.RegistrationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client registration failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("client registration failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var res clientRegistration
+	if err := json.Unmarshal(data, &res); err != nil || res.ClientID == "" {
+		return fmt.Errorf("client registration returned no client_id: %s", data)
+	}
+	e.ClientID = res.ClientID
+	e.ClientSecret = res.ClientSecret
+	return nil
+}
+
+// followAuthorizationURL requests the authorization URL and follows its redirects,
+// which ends at the loopback listener if the issuer approves without interaction.
+func followAuthorizationURL(ctx context.Context, client *http.Client, authURL string, results chan<- callbackResult) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL, nil)
+	if err == nil {
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 400 {
+				return
+			}
+			err = fmt.Errorf("authorization endpoint returned HTTP %d", resp.StatusCode)
+		}
+	}
+	select {
+	case results <- callbackResult{err: fmt.Errorf("auto-approve failed: %w", err)}:
+	default:
+	}
+}
+
+// OpenBrowser prints the authorization URL and tries to open it in the system browser.
+func OpenBrowser(_ context.Context, authURL string) error {
+	fmt.Fprintf(os.Stderr, "Open the following URL in your browser to authorize mcp-tester:\n\n  %s\n\n", authURL)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", authURL)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", authURL)
+	default:
+		cmd = exec.Command("xdg-open", authURL)
+	}
+	// Failing to launch a browser is not fatal, the URL has been printed.
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+	return nil
+}
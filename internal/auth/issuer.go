@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issuer is a minimal in-memory OAuth 2.1 authorization server. It approves every
+// authorization request without user interaction and is meant for offline tests
+// only (see test-server --require-auth). The issuer URL is derived from the Host
+// header of each request, so it works on any listen address.
+type Issuer struct {
+	// TokenTTL is the lifetime of issued access tokens.
+	TokenTTL time.Duration
+	// Scopes announced in the protected resource metadata.
+	Scopes []string
+
+	mu            sync.Mutex
+	clients       map[string]*issuerClient
+	codes         map[string]*issuedCode
+	tokens        map[string]time.Time
+	refreshTokens map[string]string
+}
+
+type issuerClient struct {
+	secret       string
+	redirectURIs []string
+}
+
+type issuedCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	expires     time.Time
+}
+
+// NewIssuer creates an Issuer whose access tokens expire after ttl.
+func NewIssuer(ttl time.Duration) *Issuer {
+	return &Issuer{
+		TokenTTL:      ttl,
+		Scopes:        []string{"mcp"},
+		clients:       make(map[string]*issuerClient),
+		codes:         make(map[string]*issuedCode),
+		tokens:        make(map[string]time.Time),
+		refreshTokens: make(map[string]string),
+	}
+}
+
+// AddClient pre-registers a confidential client, e.g. for the client credentials grant.
+func (i *Issuer) AddClient(id, secret string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.clients[id] = &issuerClient{secret: secret}
+}
+
+// Handler serves the authorization server endpoints and the protected resource
+// metadata, and requires a valid access token for every other request to next.
+func (i *Issuer) Handler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/oauth-authorization-server", i.serveServerMetadata)
+	mux.HandleFunc("/.well-known/oauth-protected-resource", i.serveResourceMetadata)
+	mux.HandleFunc("/.well-known/oauth-protected-resource/", i.serveResourceMetadata)
+	mux.HandleFunc("/authorize", i.serveAuthorize)
+	mux.HandleFunc("/token", i.serveToken)
+	mux.HandleFunc("/register", i.serveRegister)
+	mux.Handle("/", i.requireToken(next))
+	return mux
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+func (i *Issuer) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !i.valid(token) {
+			metadataURL := baseURL(r) + "/.well-known/oauth-protected-resource" + r.URL.Path
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s", scope="%s"`, metadataURL, strings.Join(i.Scopes, " ")))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (i *Issuer) valid(token string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	expires, ok := i.tokens[token]
+	return ok && time.Now().Before(expires)
+}
+
+func (i *Issuer) serveResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	writeJSON(w, http.StatusOK, &resourceMetadata{
+		Resource:             base + strings.TrimPrefix(r.URL.Path, "/.well-known/oauth-protected-resource"),
+		AuthorizationServers: []string{base},
+		ScopesSupported:      i.Scopes,
+		ResourceName:         "ultimate-test-server",
+	})
+}
+
+func (i *Issuer) serveServerMetadata(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	writeJSON(w, http.StatusOK, &serverMetadata{
+		Issuer:                            base,
+		AuthorizationEndpoint:             base + "/authorize",
+		TokenEndpoint:                     base + "/token",
+		RegistrationEndpoint:              base + "/register",
+		ScopesSupported:                   i.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials", "refresh_token"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	})
+}
+
+func (i *Issuer) serveRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var reg clientRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil || len(reg.RedirectURIs) == 0 {
+		oauthError(w, http.StatusBadRequest, "invalid_client_metadata", "redirect_uris are required")
+		return
+	}
+	reg.ClientID = rand.Text()
+	reg.ClientSecret = ""
+	if reg.TokenEndpointAuthMethod != "none" {
+		reg.ClientSecret = rand.Text()
+	}
+
+	i.mu.Lock()
+	i.clients[reg.ClientID] = &issuerClient{secret: reg.ClientSecret, redirectURIs: reg.RedirectURIs}
+	i.mu.Unlock()
+	writeJSON(w, http.StatusCreated, &reg)
+}
+
+// serveAuthorize approves the request immediately and redirects back with a code.
+func (i *Issuer) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	clientID, redirectURI := q.Get("client_id"), q.Get("redirect_uri")
+
+	i.mu.Lock()
+	client := i.clients[clientID]
+	i.mu.Unlock()
+	if client == nil {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if !redirectAllowed(client, redirectURI) {
+		http.Error(w, "redirect_uri not registered", http.StatusBadRequest)
+		return
+	}
+
+	target, _ := url.Parse(redirectURI)
+	params := target.Query()
+	params.Set("state", q.Get("state"))
+	switch {
+	case q.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "PKCE with S256 is required")
+	default:
+		code := rand.Text()
+		i.mu.Lock()
+		i.codes[code] = &issuedCode{
+			clientID:    clientID,
+			redirectURI: redirectURI,
+			challenge:   q.Get("code_challenge"),
+			expires:     time.Now().Add(time.Minute),
+		}
+		i.mu.Unlock()
+		params.Set("code", code)
+	}
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// redirectAllowed accepts registered redirect URIs. Pre-registered clients
+// without redirect URIs may use any loopback redirect.
+func redirectAllowed(c *issuerClient, redirectURI string) bool {
+	if len(c.redirectURIs) == 0 {
+		return strings.HasPrefix(redirectURI, "http://127.0.0.1:") || strings.HasPrefix(redirectURI, "http://localhost:")
+	}
+	for _, u := range c.redirectURIs {
+		if u == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Issuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	client := i.clients[clientID]
+	if client == nil || client.secret != secret {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "client_credentials":
+		writeJSON(w, http.StatusOK, i.issue(clientID, false))
+	case "authorization_code":
+		code := i.codes[r.PostForm.Get("code")]
+		delete(i.codes, r.PostForm.Get("code"))
+		if code == nil || code.clientID != clientID || time.Now().After(code.expires) {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+			return
+		}
+		if code.redirectURI != r.PostForm.Get("redirect_uri") {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, i.issue(clientID, true))
+	case "refresh_token":
+		owner, ok := i.refreshTokens[r.PostForm.Get("refresh_token")]
+		delete(i.refreshTokens, r.PostForm.Get("refresh_token"))
+		if !ok || owner != clientID {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "unknown refresh token")
+			return
+		}
+		writeJSON(w, http.StatusOK, i.issue(clientID, true))
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", r.PostForm.Get("grant_type"))
+	}
+}
+
+// issue creates a new access token and, if requested, a rotating refresh token.
+// The caller must hold i.mu.
+func (i *Issuer) issue(clientID string, withRefresh bool) map[string]any {
+	access := rand.Text()
+	i.tokens[access] = time.Now().Add(i.TokenTTL)
+	res := map[string]any{
+		"access_token": access,
+		"token_type":   "Bearer",
+		"expires_in":   int(i.TokenTTL.Seconds()),
+		"scope":        strings.Join(i.Scopes, " "),
+	}
+	if withRefresh {
+		refresh := rand.Text()
+		i.refreshTokens[refresh] = clientID
+		res["refresh_token"] = refresh
+	}
+	return res
+}
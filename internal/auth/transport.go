@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Transport is an http.RoundTripper that authorizes requests with OAuth access tokens.
+// On a 401 response it runs the configured flow once and retries the request.
+type Transport struct {
+	base     http.RoundTripper
+	resource string
+	config   *Config
+	store    *Store
+	key      string
+
+	// OpenURL presents the authorization URL to the user. Defaults to OpenBrowser.
+	OpenURL func(ctx context.Context, url string) error
+
+	mu         sync.Mutex
+	loaded     bool
+	source     oauth2.TokenSource
+	generation int
+	pending    *authCall // authorization in progress, nil if none
+}
+
+// authCall is an authorization that concurrent requests wait for instead of
+// starting their own.
+type authCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewTransport creates a Transport for the MCP server at resource. Tokens are
+// cached in store under key; store may be nil to disable caching.
+func NewTransport(base http.RoundTripper, resource string, cfg *Config, store *Store, key string) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:     base,
+		resource: resource,
+		config:   cfg,
+		store:    store,
+		key:      key,
+		OpenURL:  OpenBrowser,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	source, generation := t.currentSource()
+	outReq := req
+	if source != nil {
+		// A failed refresh falls through to a new authorization on the 401.
+		if tok, err := source.Token(); err == nil {
+			outReq = withToken(req, tok)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(outReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// The body has been consumed and cannot be replayed.
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := t.authorize(req.Context(), resp.Header, generation); err != nil {
+		return nil, fmt.Errorf("authorization failed: %w", err)
+	}
+	source, _ = t.currentSource()
+	tok, err := source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("authorization failed: %w", err)
+	}
+
+	retry := withToken(req, tok)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// withToken returns a copy of req carrying the access token.
+func withToken(req *http.Request, tok *oauth2.Token) *http.Request {
+	out := req.Clone(req.Context())
+	tok.SetAuthHeader(out)
+	return out
+}
+
+// currentSource returns the token source, loading it from the cache on first use.
+func (t *Transport) currentSource() (oauth2.TokenSource, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.loaded {
+		t.loaded = true
+		if t.store != nil {
+			if e, err := t.store.Load(t.key); err == nil && e != nil && e.Resource == t.resource && e.Token != nil {
+				t.source = t.newSource(e)
+			}
+		}
+	}
+	return t.source, t.generation
+}
+
+// authorize discovers the authorization server and obtains a new token. If another
+// request already re-authorized since generation was observed, it does nothing;
+// if another request is authorizing, it waits for that result. The lock is not
+// held during the flow, which may wait minutes for the user in the browser.
+func (t *Transport) authorize(ctx context.Context, header http.Header, generation int) error {
+	t.mu.Lock()
+	if t.generation != generation {
+		t.mu.Unlock()
+		return nil
+	}
+	if call := t.pending; call != nil {
+		t.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &authCall{done: make(chan struct{})}
+	t.pending = call
+	t.mu.Unlock()
+
+	e, err := t.obtain(ctx, header)
+	if err == nil {
+		t.save(e)
+	}
+
+	t.mu.Lock()
+	if err == nil {
+		t.source = t.newSource(e)
+		t.generation++
+	}
+	t.pending = nil
+	t.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// obtain runs the configured flow against the authorization server of the
+// resource and returns the new cache entry.
+func (t *Transport) obtain(ctx context.Context, header http.Header) (*Entry, error) {
+	flow, err := t.config.flow()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: t.base}
+	md, err := Discover(ctx, client, t.resource, header)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		Resource:     t.resource,
+		Flow:         flow,
+		TokenURL:     md.TokenEndpoint,
+		ClientID:     t.config.ClientID,
+		ClientSecret: t.config.ClientSecret,
+		Scopes:       t.config.Scopes,
+	}
+	if e.Scopes == nil {
+		e.Scopes = md.Scopes
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	if flow == FlowClientCredentials {
+		e.Token, err = clientCredentialsToken(ctx, e)
+	} else {
+		e.Token, err = authorizationCodeToken(ctx, client, t.config, md, e, t.OpenURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// newSource returns a token source that refreshes the entry's token when it
+// expires and writes every new token back to the cache.
+func (t *Transport) newSource(e *Entry) oauth2.TokenSource {
+	if t.config.ClientSecret != "" {
+		e.ClientSecret = t.config.ClientSecret
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: t.base})
+	var refresher oauth2.TokenSource
+	if e.Flow == FlowClientCredentials {
+		refresher = clientCredentialsConfig(e).TokenSource(ctx)
+	} else {
+		refresher = authCodeConfig(e, "", "").TokenSource(ctx, e.Token)
+	}
+	return &cachingSource{
+		src:   oauth2.ReuseTokenSource(e.Token, refresher),
+		entry: e,
+		last:  e.Token.AccessToken,
+		save:  t.save,
+	}
+}
+
+// save stores the entry in the cache. Cache failures only cost a re-authorization
+// on the next run, so they are not reported.
+func (t *Transport) save(e *Entry) {
+	if t.store == nil {
+		return
+	}
+	stored := *e
+	if stored.ClientSecret == t.config.ClientSecret {
+		// Configured secrets stay in the profile, only registered ones are cached.
+		stored.ClientSecret = ""
+	}
+	_ = t.store.Save(t.key, &stored)
+}
+
+// cachingSource persists tokens obtained by refreshing.
+type cachingSource struct {
+	mu    sync.Mutex
+	src   oauth2.TokenSource
+	entry *Entry
+	last  string
+	save  func(*Entry)
+}
+
+func (s *cachingSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.AccessToken != s.last {
+		s.last = tok.AccessToken
+		s.entry.Token = tok
+		s.save(s.entry)
+	}
+	return tok, nil
+}
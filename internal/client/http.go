@@ -0,0 +1,199 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/sse"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Headers that identify a Streamable HTTP session.
+var sessionHeaderKeys = []string{"Mcp-Session-Id", "Mcp-Protocol-Version"}
+
+// sessionHeaders remembers the session headers the SDK sends, so that raw
+// requests join the same Streamable HTTP session.
+type sessionHeaders struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (t *sessionHeaders) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	for _, k := range sessionHeaderKeys {
+		if v := req.Header.Get(k); v != "" {
+			t.header.Set(k, v)
+		}
+	}
+	t.mu.Unlock()
+	return t.base.RoundTrip(req)
+}
+
+func (t *sessionHeaders) apply(h http.Header) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, v := range t.header {
+		h[k] = v
+	}
+}
+
+// useHTTP sends raw calls as separate POST requests to the endpoint of st,
+// through the same HTTP client as the session.
+func (r *Raw) useHTTP(st *mcp.StreamableClientTransport) {
+	client := st.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r.headers = &sessionHeaders{base: base, header: make(http.Header)}
+	c := *client
+	c.Transport = r.headers
+	r.httpClient = &c
+
+	session := *st
+	session.HTTPClient = r.httpClient
+	r.transport = &session
+	r.endpoint = st.Endpoint
+}
+
+// callHTTP posts a request and reads its response, which is either a JSON
+// body or an SSE stream that may carry server messages before the response.
+func (r *Raw) callHTTP(ctx context.Context, req *jsonrpc.Request) (json.RawMessage, error) {
+	resp, err := r.post(ctx, req)
+	if err != nil {
+		return nil, r.interrupted(ctx, req, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if msg, err := jsonrpc.DecodeMessage(body); err == nil {
+			if res, ok := msg.(*jsonrpc.Response); ok && res.Error != nil {
+				return nil, toRPCError(res.Error)
+			}
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, r.interrupted(ctx, req, err)
+		}
+		msg, err := jsonrpc.DecodeMessage(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		if res, ok := msg.(*jsonrpc.Response); ok && res.ID == req.ID {
+			return result(res)
+		}
+		return nil, fmt.Errorf("unexpected message in response to %q", req.Method)
+	case "text/event-stream":
+		var parser sse.Parser
+		buf := make([]byte, 32*1024)
+		for {
+			n, readErr := resp.Body.Read(buf)
+			for _, ev := range parser.Feed(buf[:n]) {
+				if ev.Name != "" && ev.Name != "message" {
+					continue
+				}
+				msg, err := jsonrpc.DecodeMessage([]byte(ev.Data))
+				if err != nil {
+					return nil, fmt.Errorf("invalid message in response stream: %w", err)
+				}
+				switch m := msg.(type) {
+				case *jsonrpc.Response:
+					if m.ID == req.ID {
+						return result(m)
+					}
+				case *jsonrpc.Request:
+					r.serverMessage(ctx, m)
+				}
+			}
+			if readErr != nil {
+				if errors.Is(readErr, io.EOF) {
+					return nil, fmt.Errorf("response stream for %q ended without a response", req.Method)
+				}
+				return nil, r.interrupted(ctx, req, readErr)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected content type %q in response to %q", mediaType, req.Method)
+	}
+}
+
+// serverMessage handles a request or notification the server sends in the
+// response stream of a raw call. The SDK session never sees these, so
+// notifications go to OnNotification and requests are answered as a client
+// without sampling, roots or elicitation support does.
+func (r *Raw) serverMessage(ctx context.Context, req *jsonrpc.Request) {
+	if !req.ID.IsValid() {
+		if r.OnNotification != nil {
+			r.OnNotification(req.Method, req.Params)
+		}
+		return
+	}
+	answer := &jsonrpc.Response{ID: req.ID, Result: json.RawMessage("{}")}
+	if req.Method != "ping" {
+		answer = &jsonrpc.Response{ID: req.ID, Error: &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "method not supported"}}
+	}
+	r.send(ctx, answer)
+}
+
+// interrupted returns the error for a failed call. If ctx ended, the server is
+// told to stop working on the request, as the SDK does.
+func (r *Raw) interrupted(ctx context.Context, req *jsonrpc.Request, err error) error {
+	if ctx.Err() == nil {
+		return fmt.Errorf("failed to send %q: %w", req.Method, err)
+	}
+	if note, err := newNotification("notifications/cancelled", map[string]any{"requestId": req.ID.Raw(), "reason": ctx.Err().Error()}); err == nil {
+		r.send(context.Background(), note)
+	}
+	return ctx.Err()
+}
+
+// send posts a notification or response, which the server acknowledges
+// without content.
+func (r *Raw) send(ctx context.Context, msg jsonrpc.Message) {
+	if resp, err := r.post(ctx, msg); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (r *Raw) post(ctx context.Context, msg jsonrpc.Message) (*http.Response, error) {
+	data, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	r.headers.apply(req.Header)
+	return r.httpClient.Do(req)
+}
+
+func result(resp *jsonrpc.Response) (json.RawMessage, error) {
+	if resp.Error != nil {
+		return nil, toRPCError(resp.Error)
+	}
+	return resp.Result, nil
+}
@@ -1,16 +1,20 @@
+// Package client provides a raw JSON-RPC client for MCP servers that bypasses
+// the strict unmarshaling of the SDK.
 package client
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
-	"unsafe"
+	"net/http"
+	"sync"
 
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-// RPCError representing a JSON-RPC error
+// RPCError represents a JSON-RPC error returned by the server.
 type RPCError struct {
 	Code    int64
 	Message string
@@ -21,20 +25,132 @@ func (e *RPCError) Error() string {
 	return fmt.Sprintf("RPC error (%d): %s", e.Code, e.Message)
 }
 
-// CallToolRaw performs a tool call and returns the raw map[string]any result,
-// bypassing the strict SDK unmarshaling that fails on missing "type" fields.
-func CallToolRaw(ctx context.Context, session *mcp.ClientSession, toolName string, arguments any, meta map[string]any) (map[string]any, error) {
-	// 1. Get the internal jsonrpc2.Connection via reflection on unexported field
-	sVal := reflect.ValueOf(session).Elem()
-	connField := sVal.FieldByName("conn")
-	if !connField.IsValid() {
-		return nil, fmt.Errorf("could not find 'conn' field in ClientSession")
+// ErrNotConnected is returned by Call before the transport has been connected.
+var ErrNotConnected = errors.New("raw client is not connected")
+
+// Raw sends arbitrary JSON-RPC requests over the connection of an SDK session.
+// It wraps the session's transport, so it must be passed to Client.Connect
+// instead of the original transport:
+//
+//	raw := client.NewRaw(transport)
+//	session, err := mcpClient.Connect(ctx, raw, nil)
+//	result, err := raw.Call(ctx, "tools/list", nil)
+//
+// Raw requests use string IDs that never collide with the numeric IDs of the
+// SDK. Over stdio and SSE they are written to the session's connection and
+// their responses are taken off it before the SDK sees them. Over Streamable
+// HTTP every request is a POST of its own, so raw requests are posted next to
+// the session with its Mcp-Session-Id and Mcp-Protocol-Version headers, and
+// the SDK keeps its connection unchanged.
+type Raw struct {
+	transport mcp.Transport
+
+	// OnNotification receives the notifications a server sends in the
+	// response stream of a raw call over Streamable HTTP. Over the other
+	// transports they reach the SDK session.
+	OnNotification func(method string, params json.RawMessage)
+
+	// Set for Streamable HTTP.
+	endpoint   string
+	httpClient *http.Client
+	headers    *sessionHeaders
+
+	mu      sync.Mutex
+	conn    mcp.Connection
+	nextID  int64
+	pending map[string]chan *jsonrpc.Response
+	err     error
+}
+
+// NewRaw wraps transport for use by both an SDK session and raw calls.
+func NewRaw(transport mcp.Transport) *Raw {
+	r := &Raw{transport: transport, pending: make(map[string]chan *jsonrpc.Response)}
+	if st, ok := transport.(*mcp.StreamableClientTransport); ok {
+		r.useHTTP(st)
+	}
+	return r
+}
+
+// Connect implements mcp.Transport.
+func (r *Raw) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := r.transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.conn = conn
+	r.err = nil
+	r.mu.Unlock()
+	if r.httpClient != nil {
+		return conn, nil
+	}
+	// Only the Streamable HTTP connection implements the SDK's internal
+	// session hooks, so the connections of the other transports can be wrapped.
+	return &rawConn{Connection: conn, raw: r}, nil
+}
+
+// Call sends a request and returns the undecoded result. A JSON-RPC error
+// response is returned as *RPCError.
+func (r *Raw) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	var rawParams json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal params: %w", err)
+		}
+		rawParams = data
+	}
+
+	r.mu.Lock()
+	if r.conn == nil {
+		r.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	if r.err != nil {
+		err := r.err
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.nextID++
+	key := fmt.Sprintf("raw-%d", r.nextID)
+	id, err := jsonrpc.MakeID(key)
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	if r.httpClient != nil {
+		r.mu.Unlock()
+		return r.callHTTP(ctx, &jsonrpc.Request{ID: id, Method: method, Params: rawParams})
+	}
+	done := make(chan *jsonrpc.Response, 1)
+	r.pending[key] = done
+	conn := r.conn
+	r.mu.Unlock()
+
+	if err := conn.Write(ctx, &jsonrpc.Request{ID: id, Method: method, Params: rawParams}); err != nil {
+		r.forget(key)
+		return nil, fmt.Errorf("failed to send %q: %w", method, err)
 	}
 
-	// Create a NEW reflect.Value that is addressable and exported (using NewAt)
-	connPtr := reflect.NewAt(connField.Type().Elem(), unsafe.Pointer(connField.Pointer()))
+	select {
+	case resp := <-done:
+		if resp.Error != nil {
+			return nil, toRPCError(resp.Error)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		r.forget(key)
+		// Let the server stop working on the request, as the SDK does.
+		if note, err := newNotification("notifications/cancelled", map[string]any{"requestId": key, "reason": ctx.Err().Error()}); err == nil {
+			_ = conn.Write(context.Background(), note)
+		}
+		return nil, ctx.Err()
+	}
+}
 
-	// 2. Prepare the request
+// CallTool performs a tools/call request and returns the result as a generic
+// map, bypassing the strict SDK unmarshaling that fails on missing "type" fields.
+func (r *Raw) CallTool(ctx context.Context, toolName string, arguments any, meta map[string]any) (map[string]any, error) {
 	params := struct {
 		Name      string         `json:"name"`
 		Arguments any            `json:"arguments"`
@@ -44,88 +160,91 @@ func CallToolRaw(ctx context.Context, session *mcp.ClientSession, toolName strin
 		Arguments: arguments,
 		Meta:      meta,
 	}
-
-	// 3. Call the internal Connection.Call method
-	callMethod := connPtr.MethodByName("Call")
-	if !callMethod.IsValid() {
-		return nil, fmt.Errorf("call method not found on Connection")
+	result, err := r.Call(ctx, "tools/call", params)
+	if err != nil {
+		return nil, err
 	}
-
-	callResults := callMethod.Call([]reflect.Value{
-		reflect.ValueOf(ctx),
-		reflect.ValueOf("tools/call"),
-		reflect.ValueOf(params),
-	})
-
-	asyncCall := callResults[0]
-
-	// 4. Wait for ready
-	// asyncCall is *AsyncCall. We need to access its unexported field 'ready'
-	// Use NewAt to get an exported version of the channel field..
-	acType := asyncCall.Type().Elem()
-	readyField, _ := acType.FieldByName("ready")
-	readyPtr := reflect.NewAt(readyField.Type, unsafe.Pointer(uintptr(unsafe.Pointer(asyncCall.Pointer()))+readyField.Offset)).Elem()
-
-	done := make(chan struct{})
-	go func() {
-		readyPtr.Recv()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		// Call is ready
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	var resultMap map[string]any
+	if err := json.Unmarshal(result, &resultMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal raw response: %w", err)
 	}
+	return resultMap, nil
+}
 
-	// 5. Get response
-	respFieldInfo, _ := acType.FieldByName("response")
-	respPtr := reflect.NewAt(respFieldInfo.Type, unsafe.Pointer(uintptr(unsafe.Pointer(asyncCall.Pointer()))+respFieldInfo.Offset)).Elem()
+func (r *Raw) forget(key string) {
+	r.mu.Lock()
+	delete(r.pending, key)
+	r.mu.Unlock()
+}
 
-	if respPtr.IsNil() {
-		return nil, fmt.Errorf("response is nil after call ready")
+// deliver hands a response to a pending raw call. It reports false for
+// responses that belong to the SDK.
+func (r *Raw) deliver(resp *jsonrpc.Response) bool {
+	key, ok := resp.ID.Raw().(string)
+	if !ok {
+		return false
 	}
+	r.mu.Lock()
+	done, ok := r.pending[key]
+	delete(r.pending, key)
+	r.mu.Unlock()
+	if ok {
+		done <- resp
+	}
+	return ok
+}
 
-	// respPtr is *Response (internal/jsonrpc2.Response)
-	respVal := reflect.NewAt(respPtr.Type().Elem(), unsafe.Pointer(respPtr.Pointer())).Elem()
+// fail aborts all pending calls once the connection is broken.
+func (r *Raw) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = fmt.Errorf("connection closed: %w", err)
+	}
+	for key, done := range r.pending {
+		delete(r.pending, key)
+		done <- &jsonrpc.Response{Error: r.err}
+	}
+}
 
-	// Error check
-	errField := respVal.FieldByName("Error")
-	if !errField.IsNil() {
-		// errField is an error interface. Get the concrete value.
-		errVal := errField.Elem()
-		// If it's a pointer, get the element it points to
-		if errVal.Kind() == reflect.Ptr {
-			errVal = errVal.Elem()
+func toRPCError(err error) error {
+	var wire *jsonrpc.Error
+	if !errors.As(err, &wire) {
+		return err
+	}
+	rpcErr := &RPCError{Code: wire.Code, Message: wire.Message}
+	if len(wire.Data) > 0 {
+		if json.Unmarshal(wire.Data, &rpcErr.Data) != nil {
+			rpcErr.Data = string(wire.Data)
 		}
+	}
+	return rpcErr
+}
 
-		// Now we should have the struct
-		var code int64
-		if codeField := errVal.FieldByName("Code"); codeField.IsValid() && codeField.Kind() == reflect.Int64 {
-			code = codeField.Int()
-		}
-		var message string
-		if messageField := errVal.FieldByName("Message"); messageField.IsValid() && messageField.Kind() == reflect.String {
-			message = messageField.String()
-		}
-		var data any
-		dataField := errVal.FieldByName("Data")
-		if dataField.IsValid() {
-			data = dataField.Interface()
-		}
-		return nil, &RPCError{Code: code, Message: message, Data: data}
+func newNotification(method string, params any) (*jsonrpc.Request, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
 	}
+	return &jsonrpc.Request{Method: method, Params: data}, nil
+}
 
-	// Result check
-	resultField := respVal.FieldByName("Result")
-	// resultField is json.RawMessage ([]byte)
-	rawJSON := resultField.Bytes()
+// rawConn filters the responses to raw calls out of the message stream read by the SDK.
+type rawConn struct {
+	mcp.Connection
+	raw *Raw
+}
 
-	var resultMap map[string]any
-	if err := json.Unmarshal(rawJSON, &resultMap); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal raw response: %w", err)
+func (c *rawConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	for {
+		msg, err := c.Connection.Read(ctx)
+		if err != nil {
+			c.raw.fail(err)
+			return nil, err
+		}
+		if resp, ok := msg.(*jsonrpc.Response); ok && c.raw.deliver(resp) {
+			continue
+		}
+		return msg, nil
 	}
-
-	return resultMap, nil
 }
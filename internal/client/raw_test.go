@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connect starts an in-memory server with an echo and a slow tool and returns
+// a session connected through a raw client.
+func connect(t *testing.T) (*mcp.ClientSession, *Raw) {
+	t.Helper()
+	ctx := context.Background()
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "1.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "echo"}, func(_ context.Context, _ *mcp.CallToolRequest, args map[string]any) (*mcp.CallToolResult, any, error) {
+		msg, _ := args["message"].(string)
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: msg}}}, nil, nil
+	})
+	mcp.AddTool(server, &mcp.Tool{Name: "slow"}, func(ctx context.Context, _ *mcp.CallToolRequest, _ map[string]any) (*mcp.CallToolResult, any, error) {
+		<-ctx.Done()
+		return nil, nil, ctx.Err()
+	})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+
+	raw := NewRaw(clientTransport)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session, raw
+}
+
+func TestRawCall(t *testing.T) {
+	session, raw := connect(t)
+	ctx := context.Background()
+
+	result, err := raw.Call(ctx, "tools/list", map[string]any{})
+	if err != nil {
+		t.Fatalf("tools/list error = %v", err)
+	}
+	var list struct {
+		Tools []struct{ Name string } `json:"tools"`
+	}
+	if err := json.Unmarshal(result, &list); err != nil || len(list.Tools) != 2 {
+		t.Errorf("unexpected tools/list result %s (%v)", result, err)
+	}
+
+	res, err := raw.CallTool(ctx, "echo", map[string]any{"message": "hi"}, map[string]any{"progressToken": "p1"})
+	if err != nil {
+		t.Fatalf("CallTool error = %v", err)
+	}
+	content, _ := res["content"].([]any)
+	if len(content) != 1 || content[0].(map[string]any)["text"] != "hi" {
+		t.Errorf("unexpected tools/call result %v", res)
+	}
+
+	// The SDK session keeps working on the shared connection.
+	if err := session.Ping(ctx, nil); err != nil {
+		t.Errorf("Ping through SDK session failed: %v", err)
+	}
+}
+
+func TestRawCallError(t *testing.T) {
+	_, raw := connect(t)
+	_, err := raw.CallTool(context.Background(), "missing", map[string]any{}, nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected *RPCError, got %T: %v", err, err)
+	}
+	if rpcErr.Code != -32602 {
+		t.Errorf("code = %d; want -32602", rpcErr.Code)
+	}
+}
+
+func TestRawCallCancel(t *testing.T) {
+	session, raw := connect(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := raw.CallTool(ctx, "slow", map[string]any{}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if err := session.Ping(context.Background(), nil); err != nil {
+		t.Errorf("session unusable after cancelled raw call: %v", err)
+	}
+
+	session.Close()
+	if _, err := raw.Call(context.Background(), "ping", nil); err == nil {
+		t.Error("expected error after the connection was closed")
+	}
+}
+
+func TestRawNotConnected(t *testing.T) {
+	if _, err := NewRaw(nil).Call(context.Background(), "ping", nil); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+}
+
+func TestRawStreamableHTTP(t *testing.T) {
+	ctx := context.Background()
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "1.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "count"}, func(ctx context.Context, req *mcp.CallToolRequest, _ map[string]any) (*mcp.CallToolResult, any, error) {
+		if token := req.Params.GetProgressToken(); token != nil {
+			_ = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{ProgressToken: token, Progress: 1, Total: 2})
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "done"}}}, nil, nil
+	})
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+
+	var mu sync.Mutex
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests = append(requests, req.Clone(context.Background()))
+		mu.Unlock()
+		handler.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	raw := NewRaw(&mcp.StreamableClientTransport{Endpoint: srv.URL})
+	var notes []string
+	raw.OnNotification = func(method string, _ json.RawMessage) { notes = append(notes, method) }
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, raw, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	res, err := raw.CallTool(ctx, "count", map[string]any{}, map[string]any{"progressToken": "p1"})
+	if err != nil {
+		t.Fatalf("CallTool error = %v", err)
+	}
+	if content, _ := res["content"].([]any); len(content) != 1 {
+		t.Errorf("unexpected tools/call result %v", res)
+	}
+	if len(notes) != 1 || notes[0] != "notifications/progress" {
+		t.Errorf("notifications = %q; want the progress notification", notes)
+	}
+	if _, err := raw.CallTool(ctx, "missing", map[string]any{}, nil); err == nil {
+		t.Error("expected an error for an unknown tool")
+	}
+	if err := session.Ping(ctx, nil); err != nil {
+		t.Errorf("Ping through SDK session failed: %v", err)
+	}
+
+	// The SDK session keeps its session hooks: after initialization every
+	// request carries the protocol version and the standalone SSE stream is
+	// opened.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		var get bool
+		var missing []string
+		for _, req := range requests[1:] {
+			get = get || req.Method == http.MethodGet
+			if req.Header.Get("Mcp-Protocol-Version") == "" {
+				missing = append(missing, req.Method)
+			}
+		}
+		mu.Unlock()
+		if len(missing) > 0 {
+			t.Fatalf("requests without Mcp-Protocol-Version: %v", missing)
+		}
+		if get {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the standalone SSE stream was not opened")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
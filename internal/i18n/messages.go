@@ -42,6 +42,19 @@ const (
 	MsgInputSchema     MessageKey = "input_schema"
 	MsgOutputSchema    MessageKey = "output_schema"
 	MsgAnnotations     MessageKey = "annotations"
+	MsgSetupFailed     MessageKey = "setup_failed"
+	MsgTestCasePassed  MessageKey = "test_case_passed"
+	MsgTestCaseFailed  MessageKey = "test_case_failed"
+	MsgTestCaseSkipped MessageKey = "test_case_skipped"
+	MsgTestCaseSummary MessageKey = "test_case_summary"
+	MsgScriptSummary   MessageKey = "script_summary"
+	MsgMaxFailures     MessageKey = "max_failures"
+	MsgFailureLimit    MessageKey = "failure_limit"
+	MsgScriptsNotRun   MessageKey = "scripts_not_run"
+	MsgLintSummary     MessageKey = "lint_summary"
+	MsgSnapshotWritten MessageKey = "snapshot_written"
+	MsgSnapshotUpdated MessageKey = "snapshot_updated"
+	MsgContentSaved    MessageKey = "content_saved"
 )
 
 var messages = map[string]map[MessageKey]string{
@@ -81,6 +94,19 @@ var messages = map[string]map[MessageKey]string{
 		MsgInputSchema:     "Input Schema: %+v\n",
 		MsgOutputSchema:    "Output Schema: %+v\n",
 		MsgAnnotations:     "Annotations: %+v\n",
+		MsgSetupFailed:     "Setup failed, skipping the script.\n",
+		MsgTestCasePassed:  "--- PASS: %s (%.2fs)\n",
+		MsgTestCaseFailed:  "--- FAIL: %s (%.2fs)\n    %s\n",
+		MsgTestCaseSkipped: "--- SKIP: %s\n",
+		MsgTestCaseSummary: "Tests: %d passed, %d failed, %d skipped\n",
+		MsgMaxFailures:     "Stopping after %d failures.\n",
+		MsgFailureLimit:    "Stopping, the failure limit was reached.\n",
+		MsgScriptsNotRun:   "%d scripts not run because the failure limit was reached.\n",
+		MsgLintSummary:     "%d scripts checked, %d problems found.\n",
+		MsgSnapshotWritten: "Snapshot %s written to %s\n",
+		MsgSnapshotUpdated: "Snapshot %s updated in %s\n",
+		MsgContentSaved:    "Content %s saved (%d bytes) to %s\n",
+		MsgScriptSummary:   "\nScripts: %d run, %d passed, %d failed (%d commands executed, %d passed, %d failed)\n",
 	},
 	"de": {
 		MsgInspectionTitle: "=== MCP Server Inspektion: %s ===\n",
@@ -118,6 +144,19 @@ var messages = map[string]map[MessageKey]string{
 		MsgInputSchema:     "Input-Schema: %+v\n",
 		MsgOutputSchema:    "Output-Schema: %+v\n",
 		MsgAnnotations:     "Annotationen: %+v\n",
+		MsgSetupFailed:     "Setup fehlgeschlagen, Skript wird übersprungen.\n",
+		MsgTestCasePassed:  "--- PASS: %s (%.2fs)\n",
+		MsgTestCaseFailed:  "--- FAIL: %s (%.2fs)\n    %s\n",
+		MsgTestCaseSkipped: "--- SKIP: %s\n",
+		MsgTestCaseSummary: "Tests: %d bestanden, %d fehlgeschlagen, %d übersprungen\n",
+		MsgMaxFailures:     "Abbruch nach %d Fehlern.\n",
+		MsgFailureLimit:    "Abbruch, die Fehlergrenze wurde erreicht.\n",
+		MsgScriptsNotRun:   "%d Skripte nicht ausgeführt, da die Fehlergrenze erreicht wurde.\n",
+		MsgLintSummary:     "%d Skripte geprüft, %d Probleme gefunden.\n",
+		MsgSnapshotWritten: "Snapshot %s nach %s geschrieben\n",
+		MsgSnapshotUpdated: "Snapshot %s in %s aktualisiert\n",
+		MsgContentSaved:    "Inhalt %s (%d Bytes) nach %s gespeichert\n",
+		MsgScriptSummary:   "\nSkripte: %d ausgeführt, %d bestanden, %d fehlgeschlagen (%d Befehle ausgeführt, %d bestanden, %d fehlgeschlagen)\n",
 	},
 }
 
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Skipped   *struct{}     `xml:"skipped"`
+	SystemOut *junitOutput  `xml:"system-out"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML with one test suite per script.
+func WriteJUnit(w io.Writer, results []*scripting.TestResult) error {
+	var doc junitSuites
+	var total float64
+	for _, r := range results {
+		suite := junitSuite{Name: scriptName(r)}
+		var suiteTime float64
+		for _, tc := range testCases(r) {
+			file := tc.file
+			if file == "" {
+				file = r.Script
+			}
+			jc := junitCase{
+				Name:      tc.name,
+				Classname: suite.Name,
+				File:      file,
+				Line:      tc.line,
+				Time:      seconds(tc.durationMS),
+			}
+			if tc.output != "" {
+				jc.SystemOut = &junitOutput{Text: tc.output}
+			}
+			switch tc.status {
+			case scripting.StatusFailed:
+				jc.Failure = &junitFailure{Message: tc.failure, Text: tc.failure}
+				suite.Failures++
+			case scripting.StatusSkipped:
+				jc.Skipped = &struct{}{}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suiteTime += tc.durationMS
+			suite.Cases = append(suite.Cases, jc)
+		}
+		suite.Time = seconds(suiteTime)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		total += suiteTime
+		doc.Suites = append(doc.Suites, suite)
+	}
+	doc.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
@@ -0,0 +1,173 @@
+// Package report writes test script results as JUnit XML, TAP or JSON.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+)
+
+// Report formats.
+const (
+	FormatJUnit = "junit"
+	FormatTAP   = "tap"
+	FormatJSON  = "json"
+)
+
+// Spec is a report sink given as "format" or "format=path". Without a path,
+// or with "-", the report is written to stdout.
+type Spec struct {
+	Format string
+	Path   string
+}
+
+// ParseSpec parses a --report value.
+func ParseSpec(s string) (Spec, error) {
+	format, path, _ := strings.Cut(s, "=")
+	switch format {
+	case FormatJUnit, FormatTAP, FormatJSON:
+	default:
+		return Spec{}, fmt.Errorf("unknown report format %q (want junit, tap or json)", format)
+	}
+	if path == "-" {
+		path = ""
+	}
+	return Spec{Format: format, Path: path}, nil
+}
+
+// Write writes the results of all scripts to the sink.
+func (s Spec) Write(results []*scripting.TestResult) (err error) {
+	var w io.Writer = os.Stdout
+	if s.Path != "" {
+		f, err := os.Create(s.Path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s report: %w", s.Format, err)
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+
+	switch s.Format {
+	case FormatJUnit:
+		return WriteJUnit(w, results)
+	case FormatTAP:
+		return WriteTAP(w, results)
+	default:
+		return WriteJSON(w, results)
+	}
+}
+
+// jsonScript adds the command details to the summary of a script.
+type jsonScript struct {
+	*scripting.TestResult
+	Commands []scripting.CommandResult `json:"commands"`
+}
+
+// WriteJSON writes the results including every executed command.
+func WriteJSON(w io.Writer, results []*scripting.TestResult) error {
+	scripts := make([]jsonScript, len(results))
+	for i, r := range results {
+		scripts[i] = jsonScript{TestResult: r, Commands: r.Commands}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"scripts": scripts})
+}
+
+// testCase is a reported unit: a test block, or a command outside of any
+// test block.
+type testCase struct {
+	name       string
+	file       string
+	line       int
+	status     string
+	durationMS float64
+	failure    string
+	output     string
+}
+
+// testCases returns the reported units of a script in execution order.
+func testCases(r *scripting.TestResult) []testCase {
+	var cases []testCase
+	if r.Error != "" {
+		cases = append(cases, testCase{name: "run script", status: scripting.StatusFailed, failure: r.Error})
+	}
+	seen := make(map[string]bool)
+	for _, cmd := range r.Commands {
+		if cmd.Test == "" {
+			cases = append(cases, testCase{
+				name:       fmt.Sprintf("line %d: %s", cmd.Line, cmd.Command),
+				file:       cmd.File,
+				line:       cmd.Line,
+				status:     cmd.Status,
+				durationMS: cmd.DurationMS,
+				failure:    cmd.Failure,
+				output:     commandOutput([]scripting.CommandResult{cmd}),
+			})
+			continue
+		}
+		if seen[cmd.Test] {
+			continue
+		}
+		seen[cmd.Test] = true
+		for _, tc := range r.Tests {
+			if tc.Name == cmd.Test {
+				cases = append(cases, fromTest(r, tc))
+			}
+		}
+	}
+	// Tests without commands, e.g. skipped after a failed setup.
+	for _, tc := range r.Tests {
+		if !seen[tc.Name] {
+			seen[tc.Name] = true
+			cases = append(cases, fromTest(r, tc))
+		}
+	}
+	return cases
+}
+
+func fromTest(r *scripting.TestResult, tc scripting.TestCaseResult) testCase {
+	var cmds []scripting.CommandResult
+	for _, cmd := range r.Commands {
+		if cmd.Test == tc.Name {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return testCase{
+		name:       tc.Name,
+		file:       tc.File,
+		line:       tc.Line,
+		status:     tc.Status,
+		durationMS: tc.DurationMS,
+		failure:    tc.Failure,
+		output:     commandOutput(cmds),
+	}
+}
+
+// commandOutput lists the commands with their responses.
+func commandOutput(cmds []scripting.CommandResult) string {
+	var b strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "line %d: %s [%s, %.3fms]\n", cmd.Line, cmd.Command, cmd.Status, cmd.DurationMS)
+		if cmd.Response != "" {
+			fmt.Fprintf(&b, "%s\n", cmd.Response)
+		}
+	}
+	return b.String()
+}
+
+// scriptName returns the name used for a script in reports.
+func scriptName(r *scripting.TestResult) string {
+	if r.Script == "" {
+		return "script"
+	}
+	return r.Script
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+)
+
+func sampleResult() *scripting.TestResult {
+	return &scripting.TestResult{
+		Script:   "tests/echo.mcp",
+		Executed: 3,
+		Passed:   2,
+		Failed:   1,
+		Tests: []scripting.TestCaseResult{
+			{Name: "echo", Line: 2, Status: scripting.StatusFailed, DurationMS: 12, Failure: `line 4: assertion failed: "a" != "b"`},
+			{Name: "later", Line: 7, Status: scripting.StatusSkipped},
+		},
+		Commands: []scripting.CommandResult{
+			{Line: 1, Command: "ping", Status: scripting.StatusPassed, DurationMS: 1},
+			{Line: 3, Command: "call_tool echo message:a", Test: "echo", Status: scripting.StatusPassed, DurationMS: 10, Response: `{"content":[]}`},
+			{Line: 4, Command: "assert_equals b", Test: "echo", Status: scripting.StatusFailed, DurationMS: 2, Failure: `line 4: assertion failed: "a" != "b"`, Response: `{"content":[]}`},
+		},
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Spec
+		wantErr bool
+	}{
+		{"junit=out.xml", Spec{FormatJUnit, "out.xml"}, false},
+		{"tap", Spec{FormatTAP, ""}, false},
+		{"json=-", Spec{FormatJSON, ""}, false},
+		{"html=out.html", Spec{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSpec(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSpec(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b strings.Builder
+	if err := WriteJUnit(&b, []*scripting.TestResult{sampleResult()}); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, b.String())
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || doc.Skipped != 1 || len(doc.Suites) != 1 {
+		t.Fatalf("testsuites = %+v", doc)
+	}
+	cases := doc.Suites[0].Cases
+	if cases[0].Name != "line 1: ping" || cases[0].File != "tests/echo.mcp" || cases[0].Line != 1 {
+		t.Errorf("case 0 = %+v", cases[0])
+	}
+	if cases[1].Name != "echo" || cases[1].Failure == nil || cases[1].Time != "0.012" {
+		t.Errorf("case 1 = %+v", cases[1])
+	}
+	if !strings.Contains(cases[1].SystemOut.Text, "line 3: call_tool echo message:a [passed, 10.000ms]\n{\"content\":[]}") {
+		t.Errorf("system-out = %q", cases[1].SystemOut)
+	}
+	if cases[2].Name != "later" || cases[2].Skipped == nil {
+		t.Errorf("case 2 = %+v", cases[2])
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var b strings.Builder
+	if err := WriteTAP(&b, []*scripting.TestResult{sampleResult()}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"TAP version 13",
+		"1..3",
+		"ok 1 - tests/echo.mcp: line 1: ping",
+		"not ok 2 - tests/echo.mcp: echo",
+		"  ---",
+		`  message: "line 4: assertion failed: \"a\" != \"b\""`,
+		"  line: 2",
+		"  duration_ms: 12.000",
+	}
+	got := strings.Split(b.String(), "\n")
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("line %d = %q; want %q", i+1, got[i], w)
+		}
+	}
+	if !strings.Contains(b.String(), "ok 3 - tests/echo.mcp: later # SKIP\n") {
+		t.Errorf("missing skipped test point:\n%s", b.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b strings.Builder
+	if err := WriteJSON(&b, []*scripting.TestResult{sampleResult()}); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Scripts []struct {
+			Script   string
+			Failed   int
+			Commands []scripting.CommandResult
+		}
+	}
+	if err := json.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Scripts) != 1 || doc.Scripts[0].Script != "tests/echo.mcp" || doc.Scripts[0].Failed != 1 || len(doc.Scripts[0].Commands) != 3 {
+		t.Errorf("json report = %+v", doc)
+	}
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/scripting"
+)
+
+// WriteTAP writes the results in TAP version 13. Failed test points carry a
+// YAML block with the failure, location, duration and command output.
+func WriteTAP(w io.Writer, results []*scripting.TestResult) error {
+	var lines []string
+	n := 0
+	for _, r := range results {
+		for _, tc := range testCases(r) {
+			n++
+			desc := tc.name
+			if len(results) > 1 || r.Script != "" {
+				desc = scriptName(r) + ": " + desc
+			}
+			switch tc.status {
+			case scripting.StatusPassed:
+				lines = append(lines, fmt.Sprintf("ok %d - %s", n, desc))
+			case scripting.StatusSkipped:
+				lines = append(lines, fmt.Sprintf("ok %d - %s # SKIP", n, desc))
+			default:
+				lines = append(lines, fmt.Sprintf("not ok %d - %s", n, desc))
+				lines = append(lines, "  ---")
+				lines = append(lines, "  message: "+yamlString(tc.failure))
+				if tc.file != "" {
+					lines = append(lines, "  file: "+yamlString(tc.file))
+				}
+				lines = append(lines, fmt.Sprintf("  line: %d", tc.line))
+				lines = append(lines, fmt.Sprintf("  duration_ms: %.3f", tc.durationMS))
+				if tc.output != "" {
+					lines = append(lines, "  output: "+yamlString(tc.output))
+				}
+				lines = append(lines, "  ...")
+			}
+		}
+	}
+
+	out := "TAP version 13\n" + fmt.Sprintf("1..%d\n", n) + strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		out += "\n"
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// yamlString quotes s as a YAML double-quoted scalar, which JSON string
+// syntax is a subset of.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -1,10 +1,18 @@
 package scripting
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+	"io"
+	"math"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 func (r *Runner) handleAssertContains(lineIdx int, line string) error {
@@ -23,16 +31,16 @@ func (r *Runner) handleAssertContainsParts(lineIdx int, parts []string) error {
 	if len(parts) == 2 {
 		expected := parts[1]
 		if !strings.Contains(r.lastText, expected) && !strings.Contains(r.lastResponse, expected) {
-			return fmt.Errorf("line %d: assertion failed: last response does not contain %q", lineIdx+1, expected)
+			return assertionFailed(lineIdx, "last response does not contain %q", expected)
 		}
-		fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("last response contains %q", expected)))
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("last response contains %q", expected)))
 	} else if len(parts) >= 3 {
 		val1 := parts[1]
 		val2 := parts[2]
 		if !strings.Contains(val1, val2) {
-			return fmt.Errorf("line %d: assertion failed: %q does not contain %q", lineIdx+1, val1, val2)
+			return assertionFailed(lineIdx, "%q does not contain %q", val1, val2)
 		}
-		fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q contains %q", val1, val2)))
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q contains %q", val1, val2)))
 	}
 	return nil
 }
@@ -53,25 +61,25 @@ func (r *Runner) handleAssertEqualsParts(lineIdx int, parts []string) error {
 	if len(parts) == 2 {
 		expected := parts[1]
 		if r.lastText != expected && r.lastResponse != expected {
-			return fmt.Errorf("line %d: assertion failed: expected exactly %q, but got %q", lineIdx+1, expected, r.lastText)
+			return assertionFailed(lineIdx, "expected exactly %q, but got %q", expected, r.lastText)
 		}
-		fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("last response equals %q", expected)))
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("last response equals %q", expected)))
 	} else if len(parts) >= 3 {
 		val1 := parts[1]
 		val2 := parts[2]
 		if val1 != val2 {
-			return fmt.Errorf("line %d: assertion failed: %q != %q", lineIdx+1, val1, val2)
+			return assertionFailed(lineIdx, "%q != %q", val1, val2)
 		}
-		fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q == %q", val1, val2)))
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q == %q", val1, val2)))
 	}
 	return nil
 }
 
 func (r *Runner) handleAssertNumber(lineIdx int, val string) error {
 	if _, err := strconv.ParseFloat(val, 64); err != nil {
-		return fmt.Errorf("line %d: assertion failed: %q is not a number", lineIdx+1, val)
+		return assertionFailed(lineIdx, "%q is not a number", val)
 	}
-	fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q is a number", val)))
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q is a number", val)))
 	return nil
 }
 
@@ -82,9 +90,9 @@ func (r *Runner) handleAssertGreaterThan(lineIdx int, s1, s2 string) error {
 		return fmt.Errorf("line %d: assert_gt arguments must be numbers", lineIdx+1)
 	}
 	if v1 <= v2 {
-		return fmt.Errorf("line %d: assertion failed: %f is not greater than %f", lineIdx+1, v1, v2)
+		return assertionFailed(lineIdx, "%f is not greater than %f", v1, v2)
 	}
-	fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%f > %f", v1, v2)))
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%f > %f", v1, v2)))
 	return nil
 }
 
@@ -121,9 +129,9 @@ func (r *Runner) handleAssertStringLengthCommand(lineIdx int, parts []string) er
 func (r *Runner) handleAssertStringLength(lineIdx int, val string, min, max int) error {
 	length := len(val)
 	if length < min || length > max {
-		return fmt.Errorf("line %d: assertion failed: string length %d is not between %d and %d", lineIdx+1, length, min, max)
+		return assertionFailed(lineIdx, "string length %d is not between %d and %d", length, min, max)
 	}
-	fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("string length %d is between %d and %d", length, min, max)))
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("string length %d is between %d and %d", length, min, max)))
 	return nil
 }
 
@@ -136,8 +144,308 @@ func (r *Runner) handleAssertErrorCodeCommand(lineIdx int, parts []string) error
 		return fmt.Errorf("line %d: invalid error code: %s", lineIdx+1, parts[1])
 	}
 	if r.lastErrorCode != code {
-		return fmt.Errorf("line %d: assertion failed: expected error code %d, got %d", lineIdx+1, code, r.lastErrorCode)
+		return assertionFailed(lineIdx, "expected error code %d, got %d", code, r.lastErrorCode)
 	}
-	fmt.Print(i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("error code is %d", code)))
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("error code is %d", code)))
 	return nil
 }
+
+// handleAssertPathCommand compares the value at a JSONPath in the last
+// response: assert_path <path> <op> <value>. A path with several matches is
+// compared as JSON array.
+func (r *Runner) handleAssertPathCommand(lineIdx int, parts []string) error {
+	if len(parts) != 4 {
+		return fmt.Errorf("line %d: assert_path expects <path> <op> <value>", lineIdx+1)
+	}
+	path, op, expected := parts[1], parts[2], parts[3]
+	val, err := r.extractValue(path)
+	if err != nil {
+		return assertionFailed(lineIdx, "%w", err)
+	}
+	actual := formatValue(val)
+	ok, err := compareValues(actual, op, expected)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", lineIdx+1, err)
+	}
+	if !ok {
+		return assertionFailed(lineIdx, "%s is %q, expected %s %q", path, actual, op, expected)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%s %s %q", path, op, expected)))
+	return nil
+}
+
+// handleAssertSchemaCommand validates the last response: assert_schema
+// [schemaFile]. Without a file the structuredContent of the last tool result
+// is checked against the tool's outputSchema. With a file the
+// structuredContent, or the whole response if there is none, is checked
+// against the schema in the file.
+func (r *Runner) handleAssertSchemaCommand(lineIdx int, parts []string) error {
+	if len(parts) > 2 {
+		return fmt.Errorf("line %d: assert_schema expects [schemaFile]", lineIdx+1)
+	}
+	if r.lastRawMap == nil {
+		return fmt.Errorf("line %d: assert_schema: no previous response available", lineIdx+1)
+	}
+
+	if len(parts) == 1 {
+		if r.lastTool == nil || r.lastTool.OutputSchema == nil {
+			return fmt.Errorf("line %d: assert_schema: the last tool call has no outputSchema, give a schema file", lineIdx+1)
+		}
+		if err := r.checkOutputSchema(); err != nil {
+			return assertionFailed(lineIdx, "%w", err)
+		}
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("result matches the outputSchema of %s", r.lastTool.Name)))
+		return nil
+	}
+
+	schema, err := r.loadSchemaFile(parts[1])
+	if err != nil {
+		return fmt.Errorf("line %d: %w", lineIdx+1, err)
+	}
+	instance, ok := r.lastRawMap["structuredContent"]
+	if !ok {
+		instance = r.lastRawMap
+	}
+	if err := validateSchema(schema, instance); err != nil {
+		var schemaErr *schemaError
+		if errors.As(err, &schemaErr) {
+			return assertionFailed(lineIdx, "response does not match %s: %w", parts[1], err)
+		}
+		return fmt.Errorf("line %d: %s: %w", lineIdx+1, parts[1], err)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("response matches %s", parts[1])))
+	return nil
+}
+
+// lastValue returns the last response as decoded JSON for assertions
+// without an explicit value: the structuredContent of a tool result if there
+// is one, otherwise the response text, decoded if it is JSON.
+func (r *Runner) lastValue() any {
+	if sc, ok := r.lastRawMap["structuredContent"]; ok {
+		return sc
+	}
+	return parseValue(r.lastText)
+}
+
+// parseValue decodes s if it is JSON and returns it as string otherwise.
+func parseValue(s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+// assertOperands splits the arguments of an assertion that compares the last
+// response or an explicit value with n further arguments: with n arguments
+// the last response is used, with n+1 the first argument. An explicit value
+// that is a single $name or ${expr} keeps the type of the variable.
+func (r *Runner) assertOperands(lineIdx int, parts []string, n int, usage string) (value any, label string, args []string, err error) {
+	switch len(parts) - 1 {
+	case n:
+		if r.lastRawMap == nil {
+			return nil, "", nil, fmt.Errorf("line %d: %s: no previous response available", lineIdx+1, parts[0])
+		}
+		return r.lastValue(), "last response", parts[1:], nil
+	case n + 1:
+		value = parseValue(parts[1])
+		if values := r.argValuesFor(parts); values != nil && values[1].ok && !values[1].keyed {
+			value = values[1].value
+		}
+		return value, fmt.Sprintf("%q", parts[1]), parts[2:], nil
+	}
+	return nil, "", nil, fmt.Errorf("line %d: %s expects %s", lineIdx+1, parts[0], usage)
+}
+
+func (r *Runner) handleAssertMatchesCommand(lineIdx int, parts []string) error {
+	if len(parts) != 2 && len(parts) != 3 {
+		return fmt.Errorf("line %d: assert_matches expects [value] <regex>", lineIdx+1)
+	}
+	re, err := regexp.Compile(parts[len(parts)-1])
+	if err != nil {
+		return fmt.Errorf("line %d: invalid regular expression: %w", lineIdx+1, err)
+	}
+	if len(parts) == 2 {
+		if r.lastRawMap == nil {
+			return fmt.Errorf("line %d: assert_matches: no previous response available", lineIdx+1)
+		}
+		if !re.MatchString(r.lastText) && !re.MatchString(r.lastResponse) {
+			return assertionFailed(lineIdx, "last response does not match %s", re)
+		}
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("last response matches %s", re)))
+		return nil
+	}
+	if !re.MatchString(parts[1]) {
+		return assertionFailed(lineIdx, "%q does not match %s", parts[1], re)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%q matches %s", parts[1], re)))
+	return nil
+}
+
+// handleAssertCompareCommand handles assert_lt, assert_lte and assert_gte:
+// assert_lt [value] <limit>.
+func (r *Runner) handleAssertCompareCommand(lineIdx int, parts []string, op string) error {
+	value, label, args, err := r.assertOperands(lineIdx, parts, 1, "[value] <number>")
+	if err != nil {
+		return err
+	}
+	actual := formatValue(value)
+	if _, err := strconv.ParseFloat(actual, 64); err != nil {
+		return assertionFailed(lineIdx, "%s is not a number", label)
+	}
+	ok, err := compareValues(actual, op, args[0])
+	if err != nil {
+		return fmt.Errorf("line %d: %s arguments must be numbers", lineIdx+1, parts[0])
+	}
+	if !ok {
+		return assertionFailed(lineIdx, "%s is not %s %s", actual, op, args[0])
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%s %s %s", actual, op, args[0])))
+	return nil
+}
+
+func (r *Runner) handleAssertBetweenCommand(lineIdx int, parts []string) error {
+	value, label, args, err := r.assertOperands(lineIdx, parts, 2, "[value] <min> <max>")
+	if err != nil {
+		return err
+	}
+	actual := formatValue(value)
+	v, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return assertionFailed(lineIdx, "%s is not a number", label)
+	}
+	lo, err1 := strconv.ParseFloat(args[0], 64)
+	hi, err2 := strconv.ParseFloat(args[1], 64)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("line %d: assert_between min and max must be numbers", lineIdx+1)
+	}
+	if v < lo || v > hi {
+		return assertionFailed(lineIdx, "%s is not between %s and %s", actual, args[0], args[1])
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%s is between %s and %s", actual, args[0], args[1])))
+	return nil
+}
+
+// assertTypes are the type names accepted by assert_type.
+var assertTypes = []string{"string", "number", "integer", "boolean", "null", "array", "object"}
+
+func (r *Runner) handleAssertTypeCommand(lineIdx int, parts []string) error {
+	value, label, args, err := r.assertOperands(lineIdx, parts, 1, "[value] <type>")
+	if err != nil {
+		return err
+	}
+	want := args[0]
+	if !slices.Contains(assertTypes, want) {
+		return fmt.Errorf("line %d: unknown type %s (expected one of %s)", lineIdx+1, want, strings.Join(assertTypes, ", "))
+	}
+	got := jsonType(value)
+	ok := got == want
+	if want == "integer" {
+		f, isNum := value.(float64)
+		ok = isNum && f == math.Trunc(f)
+	}
+	if !ok {
+		return assertionFailed(lineIdx, "%s is %s, not %s", label, got, want)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%s is %s", label, want)))
+	return nil
+}
+
+// valueLen returns the number of items of an array, keys of an object or
+// characters of a string.
+func valueLen(v any) (int, bool) {
+	switch c := v.(type) {
+	case []any:
+		return len(c), true
+	case map[string]any:
+		return len(c), true
+	case string:
+		return utf8.RuneCountInString(c), true
+	}
+	return 0, false
+}
+
+func (r *Runner) handleAssertLenCommand(lineIdx int, parts []string) error {
+	value, label, args, err := r.assertOperands(lineIdx, parts, 1, "[value] <length>")
+	if err != nil {
+		return err
+	}
+	want, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("line %d: assert_len length must be an integer", lineIdx+1)
+	}
+	if len(parts) == 3 {
+		// Explicit values that are not JSON arrays or objects count as strings.
+		switch value.(type) {
+		case []any, map[string]any:
+		default:
+			value = parts[1]
+		}
+	}
+	n, ok := valueLen(value)
+	if !ok {
+		return assertionFailed(lineIdx, "%s is %s, which has no length", label, jsonType(value))
+	}
+	if n != want {
+		return assertionFailed(lineIdx, "length of %s is %d, expected %d", label, n, want)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("length of %s is %d", label, n)))
+	return nil
+}
+
+func (r *Runner) handleAssertEmptyCommand(lineIdx int, parts []string) error {
+	value, label, _, err := r.assertOperands(lineIdx, parts, 0, "[value]")
+	if err != nil {
+		return err
+	}
+	if n, ok := valueLen(value); value != nil && (!ok || n > 0) {
+		return assertionFailed(lineIdx, "%s is not empty", label)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("%s is empty", label)))
+	return nil
+}
+
+// handleNotCommand negates an assertion: not <assert_...> [args...]. It
+// passes if the assertion fails; other errors, such as invalid arguments,
+// are returned unchanged.
+func (r *Runner) handleNotCommand(ctx context.Context, lineIdx int, parts []string) error {
+	if len(parts) < 2 || !strings.HasPrefix(parts[1], "assert_") {
+		return fmt.Errorf("line %d: not expects an assert_ command", lineIdx+1)
+	}
+	out := r.Output
+	r.Output = io.Discard
+	err := r.dispatchParts(ctx, lineIdx, parts[1:])
+	r.Output = out
+
+	desc := strings.Join(parts[1:], " ")
+	if err == nil {
+		return assertionFailed(lineIdx, "%s passed", desc)
+	}
+	if !isAssertionFailure(err) {
+		return err
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, "not "+desc))
+	return nil
+}
+
+// assertionError is a failed assertion, as opposed to an invalid command or
+// a failed request.
+type assertionError struct {
+	err error
+}
+
+func (e *assertionError) Error() string { return e.err.Error() }
+func (e *assertionError) Unwrap() error { return e.err }
+
+// assertionFailed returns the assertionError for line lineIdx, with a message
+// of the form "line N: assertion failed: ...".
+func assertionFailed(lineIdx int, format string, args ...any) error {
+	return &assertionError{err: fmt.Errorf("line %d: assertion failed: "+format, append([]any{lineIdx + 1}, args...)...)}
+}
+
+// isAssertionFailure reports whether err is a failed assertion rather than
+// an invalid command.
+func isAssertionFailure(err error) bool {
+	var assertErr *assertionError
+	return errors.As(err, &assertErr)
+}
@@ -0,0 +1,414 @@
+package scripting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+)
+
+// maxCallDepth limits recursion of script functions.
+const maxCallDepth = 64
+
+// runState counts executed commands and receives their errors.
+type runState struct {
+	executed int
+	passed   int
+	failed   int
+	onError  func(error)
+	onTest   func(TestCaseResult)
+	calls    []string // active function calls, innermost last
+	test     *TestCaseResult
+	tests    []TestCaseResult
+	commands []CommandResult
+
+	maxFailures int                    // stop after this many failed commands, 0 for no limit
+	onCommand   func(failed bool) bool // stop if it returns false, see Runner.OnCommand
+	halted      bool                   // maxFailures was reached or onCommand returned false
+}
+
+// aborted reports whether the rest of the current test is skipped after a
+// failure.
+func (s *runState) aborted() bool {
+	return s.test != nil && s.test.Status == StatusFailed
+}
+
+// record counts one executed command with its outcome.
+func (s *runState) record(at pos, err error) {
+	s.recordCommand(at, 0, "", err)
+}
+
+// recordCommand counts one executed command and keeps its details for
+// reports. response is the response the command received or, for a failed
+// command, the response it was checked against.
+func (s *runState) recordCommand(at pos, duration time.Duration, response string, err error) {
+	s.executed++
+	if s.onCommand != nil && !s.onCommand(err != nil) {
+		s.halted = true
+	}
+	cr := CommandResult{
+		File:       at.file,
+		Line:       at.idx + 1,
+		Command:    at.code,
+		Status:     StatusPassed,
+		DurationMS: durationMS(duration),
+		Response:   response,
+	}
+	if s.test != nil {
+		cr.Test = s.test.Name
+	}
+	if err == nil {
+		s.passed++
+		s.commands = append(s.commands, cr)
+		return
+	}
+	s.failed++
+	if s.maxFailures > 0 && s.failed >= s.maxFailures {
+		s.halted = true
+	}
+	err = at.wrap(err)
+	for i := len(s.calls) - 1; i >= 0; i-- {
+		err = fmt.Errorf("%w (in %s)", err, s.calls[i])
+	}
+	cr.Status = StatusFailed
+	cr.Failure = err.Error()
+	s.commands = append(s.commands, cr)
+	if s.test != nil && s.test.Status != StatusFailed {
+		s.test.Status = StatusFailed
+		s.test.Failure = err.Error()
+	}
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// splitFixtures separates setup and teardown sections from the other
+// statements. Teardowns are returned in reverse order, so fixtures are torn
+// down in the opposite order of their setup.
+func splitFixtures(nodes []node) (setup, body, teardown []node) {
+	for _, n := range nodes {
+		f, ok := n.(*fixtureNode)
+		switch {
+		case !ok:
+			body = append(body, n)
+		case f.teardown:
+			teardown = append([]node{f}, teardown...)
+		default:
+			setup = append(setup, f)
+		}
+	}
+	return setup, body, teardown
+}
+
+// define registers the functions declared in nodes.
+func (r *Runner) define(nodes []node) {
+	for _, n := range nodes {
+		if def, ok := n.(*defNode); ok {
+			if r.funcs == nil {
+				r.funcs = make(map[string]*defNode)
+			}
+			r.funcs[def.name] = def
+		}
+	}
+}
+
+// exec executes nodes in order. A failing command does not stop the
+// statements after it, except inside a test, which ends at its first failure,
+// and once the maximum number of failures is reached. Tests that are not run
+// because of the latter are recorded as skipped.
+func (r *Runner) exec(ctx context.Context, nodes []node, state *runState) {
+	for _, n := range nodes {
+		if state.halted {
+			state.skipTests([]node{n})
+			continue
+		}
+		if state.aborted() {
+			return
+		}
+		switch n := n.(type) {
+		case *commandNode:
+			r.execCommand(ctx, n, state)
+		case *ifNode:
+			ok, err := r.evalCondition(n.idx, n.cond)
+			if err != nil {
+				state.record(n.pos, err)
+			} else if ok {
+				r.exec(ctx, n.then, state)
+			} else {
+				r.exec(ctx, n.els, state)
+			}
+		case *forNode:
+			items, err := r.evalList(n.idx, n.list)
+			if err != nil {
+				state.record(n.pos, err)
+				continue
+			}
+			r.pushScope(false)
+			for _, item := range items {
+				if state.aborted() || state.halted {
+					break
+				}
+				r.bindVar(n.name, item)
+				r.exec(ctx, n.body, state)
+			}
+			r.popScope()
+		case *repeatNode:
+			countText, err := r.replaceVariables(n.count)
+			if err != nil {
+				state.record(n.pos, fmt.Errorf("line %d: %w", n.idx+1, err))
+				continue
+			}
+			count, err := strconv.Atoi(countText)
+			if err != nil || count < 0 {
+				state.record(n.pos, fmt.Errorf("line %d: invalid repeat count %q", n.idx+1, n.count))
+				continue
+			}
+			for range count {
+				if state.aborted() || state.halted {
+					break
+				}
+				r.exec(ctx, n.body, state)
+			}
+		case *letNode:
+			r.execLet(n, state)
+		case *defNode:
+			r.define([]node{n})
+		case *fixtureNode:
+			r.exec(ctx, n.body, state)
+		case *testNode:
+			r.runTest(ctx, n, state)
+		}
+	}
+}
+
+// runTest executes a test case and records its result.
+func (r *Runner) runTest(ctx context.Context, n *testNode, state *runState) {
+	tc := &TestCaseResult{Name: n.name, File: n.file, Line: n.idx + 1, Status: StatusPassed}
+	state.test = tc
+	start := time.Now()
+	r.exec(ctx, n.body, state)
+	tc.DurationMS = durationMS(time.Since(start))
+	state.test = nil
+	state.addTest(*tc)
+}
+
+func (s *runState) addTest(tc TestCaseResult) {
+	s.tests = append(s.tests, tc)
+	if s.onTest != nil {
+		s.onTest(tc)
+	}
+}
+
+// skipTests records the tests in nodes as skipped.
+func (s *runState) skipTests(nodes []node) {
+	for _, n := range nodes {
+		if t, ok := n.(*testNode); ok {
+			s.addTest(TestCaseResult{Name: t.name, File: t.file, Line: t.idx + 1, Status: StatusSkipped})
+		}
+	}
+}
+
+func (r *Runner) execCommand(ctx context.Context, n *commandNode, state *runState) {
+	parts, err := r.parseArgs(n.text)
+	if err != nil {
+		state.record(n.pos, fmt.Errorf("line %d: failed to parse command: %w", n.idx+1, err))
+		return
+	}
+	parts, values, err := r.expandArgs(parts)
+	if err != nil {
+		state.record(n.pos, fmt.Errorf("line %d: %w", n.idx+1, err))
+		return
+	}
+	if n.heredoc != nil {
+		parts = append(parts, *n.heredoc)
+		values = append(values, argValue{})
+	}
+	if len(parts) > 0 {
+		if fn, ok := r.funcs[parts[0]]; ok {
+			r.callFunction(ctx, n.pos, fn, parts[1:], values[1:], state)
+			return
+		}
+	}
+	before := r.lastResponse
+	start := time.Now()
+	r.argValues = values
+	err = r.dispatchParts(ctx, n.idx, parts)
+	r.argValues = nil
+	response := ""
+	if err != nil || r.lastResponse != before {
+		response = r.lastResponse
+	}
+	state.recordCommand(n.pos, time.Since(start), response, err)
+}
+
+// execLet evaluates the expression of a let statement and stores the result.
+func (r *Runner) execLet(n *letNode, state *runState) {
+	v, err := evalExpr(n.expr, r.lookupVar)
+	if err != nil {
+		state.record(n.pos, fmt.Errorf("line %d: %w", n.idx+1, err))
+		return
+	}
+	r.letVar(n.name, v)
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgVariableSet, n.name, formatValue(v)))
+	state.record(n.pos, nil)
+}
+
+// callFunction runs the body of fn in a new scope with its parameters bound
+// to args. Arguments that are a single variable or expression keep the type
+// of their value, others are decoded if they are JSON.
+func (r *Runner) callFunction(ctx context.Context, at pos, fn *defNode, args []string, values []argValue, state *runState) {
+	if len(args) != len(fn.params) {
+		state.record(at, fmt.Errorf("line %d: %s expects %d arguments, got %d", at.idx+1, fn.name, len(fn.params), len(args)))
+		return
+	}
+	if len(state.calls) >= maxCallDepth {
+		state.record(at, fmt.Errorf("line %d: maximum call depth of %d exceeded in %s", at.idx+1, maxCallDepth, fn.name))
+		return
+	}
+
+	r.pushScope(true)
+	defer r.popScope()
+	for i, p := range fn.params {
+		if values[i].ok {
+			r.bindVar(p, values[i].value)
+		} else {
+			r.bindVar(p, parseValue(args[i]))
+		}
+	}
+	state.calls = append(state.calls, fmt.Sprintf("%s called at %s", fn.name, at))
+	defer func() { state.calls = state.calls[:len(state.calls)-1] }()
+	r.exec(ctx, fn.body, state)
+}
+
+// evalCondition evaluates an if condition after variable substitution:
+//
+//	<value>                      true unless empty, "false", "0" or "null"
+//	<a> == != < <= > >= <b>      numeric if both sides are numbers
+//	<a> contains <b>
+//	not <condition>
+func (r *Runner) evalCondition(idx int, cond string) (bool, error) {
+	parts, err := r.parseArgs(cond)
+	if err != nil {
+		return false, fmt.Errorf("line %d: failed to parse condition: %w", idx+1, err)
+	}
+	if parts, _, err = r.expandArgs(parts); err != nil {
+		return false, fmt.Errorf("line %d: %w", idx+1, err)
+	}
+	negate := false
+	for len(parts) > 0 && parts[0] == "not" {
+		negate = !negate
+		parts = parts[1:]
+	}
+
+	var result bool
+	switch len(parts) {
+	case 0:
+		result = false
+	case 1:
+		switch parts[0] {
+		case "", "false", "0", "null":
+			result = false
+		default:
+			result = true
+		}
+	case 3:
+		result, err = compareValues(parts[0], parts[1], parts[2])
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", idx+1, err)
+		}
+	default:
+		return false, fmt.Errorf("line %d: invalid condition: %s", idx+1, cond)
+	}
+	return result != negate, nil
+}
+
+func compareValues(a, op, b string) (bool, error) {
+	if op == "contains" {
+		return strings.Contains(a, b), nil
+	}
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	numeric := errA == nil && errB == nil
+
+	switch op {
+	case "==":
+		if numeric {
+			return x == y, nil
+		}
+		return a == b, nil
+	case "!=":
+		if numeric {
+			return x != y, nil
+		}
+		return a != b, nil
+	case "<", "<=", ">", ">=":
+		if !numeric {
+			return false, fmt.Errorf("cannot compare %q %s %q: both values must be numbers", a, op, b)
+		}
+		switch op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		default:
+			return x >= y, nil
+		}
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// evalList returns the items of a for list after variable substitution. The
+// list is a variable or expression holding an array, a JSON array, an integer
+// range "a..b" or space-separated values.
+func (r *Runner) evalList(idx int, list string) ([]any, error) {
+	ref, err := r.referenceValue(strings.TrimSpace(list))
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", idx+1, err)
+	}
+	if items, ok := ref.value.([]any); ok {
+		return items, nil
+	}
+	list, err = r.replaceVariables(list)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", idx+1, err)
+	}
+	list = strings.TrimSpace(list)
+	if strings.HasPrefix(list, "[") {
+		var items []any
+		if err := json.Unmarshal([]byte(list), &items); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON list: %w", idx+1, err)
+		}
+		return items, nil
+	}
+	if from, to, ok := strings.Cut(list, ".."); ok && !strings.Contains(list, " ") {
+		a, errA := strconv.Atoi(from)
+		b, errB := strconv.Atoi(to)
+		if errA != nil || errB != nil {
+			return nil, fmt.Errorf("line %d: invalid range %q", idx+1, list)
+		}
+		var items []any
+		for n := a; n <= b; n++ {
+			items = append(items, float64(n))
+		}
+		return items, nil
+	}
+	words, err := r.parseArgs(list)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]any, len(words))
+	for i, w := range words {
+		items[i] = parseValue(w)
+	}
+	return items, nil
+}
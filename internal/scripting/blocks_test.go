@@ -0,0 +1,343 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func runBlocks(t *testing.T, r *Runner, script string) (*runState, []string) {
+	t.Helper()
+	nodes, err := r.parse(strings.Split(script, "\n"), 0, source{})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	r.define(nodes)
+	var errs []string
+	state := &runState{onError: func(err error) { errs = append(errs, err.Error()) }}
+	r.exec(context.Background(), nodes, state)
+	return state, errs
+}
+
+func TestExecBlocks(t *testing.T) {
+	r := &Runner{variables: map[string]any{"x": "outer"}}
+	state, errs := runBlocks(t, r, `check 1 1
+def check(got, want)
+  assert_equals $got $want
+end
+for $x in 1..3
+  if $x == 1
+    check $x 1
+  else if $x >= 3
+    check $x 3
+  else
+    assert_equals $x 2
+  end
+end
+for $item in ["a", 2, 3.5]
+  assert_contains "a 2 3.5" $item
+end
+repeat 2
+  if not $x contains out
+    assert_equals fail no
+  end
+end`)
+	if len(errs) != 0 || state.executed != 7 || state.passed != 7 {
+		t.Errorf("executed %d, passed %d, errors %q; want 7 passed", state.executed, state.passed, errs)
+	}
+	if r.variables["x"] != "outer" {
+		t.Errorf("loop variable not restored: x = %q", r.variables["x"])
+	}
+	if _, ok := r.variables["got"]; ok {
+		t.Error("function parameter leaked into script variables")
+	}
+}
+
+func TestExecBlockErrors(t *testing.T) {
+	r := &Runner{variables: map[string]any{}}
+	state, errs := runBlocks(t, r, `def check(got, want)
+  assert_equals $got $want
+end
+
+check 1 2
+check 1
+repeat many
+  ping
+end
+if a < b
+  ping
+end
+def loop()
+  loop
+end
+loop`)
+	want := []string{
+		`line 2: assertion failed: "1" != "2" (in check called at line 5)`,
+		"line 6: check expects 2 arguments, got 1",
+		`line 7: invalid repeat count "many"`,
+		`line 10: cannot compare "a" < "b": both values must be numbers`,
+		"line 14: maximum call depth of 64 exceeded in loop (in loop called at line 14)",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %q; want %d errors", errs, len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(errs[i], want[i]) {
+			t.Errorf("error %d = %q; want prefix %q", i, errs[i], want[i])
+		}
+	}
+	if state.failed != len(want) || state.passed != 0 {
+		t.Errorf("passed %d, failed %d", state.passed, state.failed)
+	}
+}
+
+func TestEvalCondition(t *testing.T) {
+	r := &Runner{variables: map[string]any{"status": "ok", "count": "10"}}
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"$status", true},
+		{"false", false},
+		{"0", false},
+		{"not null", true},
+		{"$status == ok", true},
+		{`$status != "ok"`, false},
+		{"$count > 9.5", true},
+		{"$count <= 9", false},
+		{"10.0 == $count", true},
+		{"$status contains k", true},
+		{"not not $count >= 10", true},
+	}
+	for _, tt := range tests {
+		got, err := r.evalCondition(0, tt.cond)
+		if err != nil || got != tt.want {
+			t.Errorf("evalCondition(%q) = %v, %v; want %v", tt.cond, got, err, tt.want)
+		}
+	}
+	if _, err := r.evalCondition(0, "a b"); err == nil {
+		t.Error("expected error for invalid condition")
+	}
+}
+
+func TestInteractiveBlocks(t *testing.T) {
+	in := NewInteractive(&Runner{variables: map[string]any{}})
+	ctx := context.Background()
+
+	for _, line := range []string{"def check(v)", "  assert_equals $v ok", "end"} {
+		if _, err := in.Exec(ctx, line); err != nil {
+			t.Fatalf("Exec(%q): %v", line, err)
+		}
+	}
+	if in.Pending() {
+		t.Fatal("def should be complete")
+	}
+
+	steps := []struct {
+		line     string
+		pending  bool
+		executed bool
+		err      string
+	}{
+		{"repeat 2", true, false, ""},
+		{"  check ok", true, false, ""},
+		{"end", false, true, ""},
+		{"check bad", false, true, `line 2: assertion failed: "bad" != "ok" (in check called at line 7)`},
+		{"end", false, false, "line 8: end without block"},
+	}
+	for _, s := range steps {
+		executed, err := in.Exec(ctx, s.line)
+		if in.Pending() != s.pending || executed != s.executed {
+			t.Errorf("Exec(%q): pending %v, executed %v; want %v, %v", s.line, in.Pending(), executed, s.pending, s.executed)
+		}
+		if got := fmt.Sprint(err); (s.err == "" && err != nil) || (s.err != "" && !strings.HasPrefix(got, s.err)) {
+			t.Errorf("Exec(%q) error = %v; want %q", s.line, err, s.err)
+		}
+	}
+}
+
+func TestRunTestCases(t *testing.T) {
+	r := &Runner{variables: map[string]any{}}
+	result, err := r.Run(context.Background(), `assert_equals outside outside
+test "passing"
+  repeat 2
+    assert_equals a a
+  end
+end
+test "failing"
+  for $x in 1..3
+    assert_equals $x 1
+    assert_equals never never
+  end
+  assert_equals after after
+end
+test "still runs"
+  assert_equals b b
+end`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tests) != 3 {
+		t.Fatalf("tests = %+v; want 3", result.Tests)
+	}
+	want := []struct{ name, status, failure string }{
+		{"passing", StatusPassed, ""},
+		{"failing", StatusFailed, `line 9: assertion failed: "2" != "1"`},
+		{"still runs", StatusPassed, ""},
+	}
+	for i, w := range want {
+		tc := result.Tests[i]
+		if tc.Name != w.name || tc.Status != w.status || tc.Failure != w.failure {
+			t.Errorf("test %d = %+v; want %+v", i, tc, w)
+		}
+	}
+	if result.Executed != 7 || result.Failed != 1 {
+		t.Errorf("result = %d executed, %d failed; want 7, 1", result.Executed, result.Failed)
+	}
+
+	result, err = r.Run(context.Background(), "setup\n  assert_equals a b\nend\ntest \"skipped\"\n  ping\nend", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tests) != 1 || result.Tests[0].Status != StatusSkipped {
+		t.Errorf("tests after failed setup = %+v; want skipped", result.Tests)
+	}
+}
+
+func TestRunMaxFailures(t *testing.T) {
+	r := &Runner{variables: map[string]any{}, MaxFailures: 2}
+	result, err := r.Run(context.Background(), `teardown
+  assert_equals cleanup cleanup
+end
+assert_equals a b
+test "first"
+  assert_equals c d
+end
+test "second"
+  assert_equals e e
+end
+assert_equals f f`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Stopped || result.Failed != 2 || result.Executed != 3 {
+		t.Errorf("result = stopped %v, %d executed, %d failed; want stopped, 3, 2", result.Stopped, result.Executed, result.Failed)
+	}
+	if len(result.Tests) != 2 || result.Tests[1].Status != StatusSkipped {
+		t.Errorf("tests = %+v; want second skipped", result.Tests)
+	}
+
+	if _, err := r.Run(context.Background(), "if a\n  ping", ""); err == nil {
+		t.Error("unclosed block: want syntax error")
+	} else if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("error = %T; want *SyntaxError", err)
+	}
+}
+
+func TestRunOnCommand(t *testing.T) {
+	// A limit shared with other scripts: one failure is left, and another
+	// script uses it up while this one runs its second command.
+	var calls []bool
+	r := &Runner{variables: map[string]any{}, OnCommand: func(failed bool) bool {
+		calls = append(calls, failed)
+		return len(calls) < 2
+	}}
+	result, err := r.Run(context.Background(), `teardown
+  assert_equals cleanup other
+end
+assert_equals a a
+assert_equals b b
+assert_equals c c`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Stopped || result.Executed != 3 || result.Failed != 1 {
+		t.Errorf("result = stopped %v, %d executed, %d failed; want stopped, 3, 1", result.Stopped, result.Executed, result.Failed)
+	}
+	// The teardown runs completely and its failure is still counted.
+	if want := []bool{false, false, true}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("OnCommand calls = %v; want %v", calls, want)
+	}
+}
+
+func TestExecScopes(t *testing.T) {
+	r := &Runner{variables: map[string]any{"total": 0.0}, Output: io.Discard}
+	state, errs := runBlocks(t, r, `def add(n)
+  let total = total + n
+  let local = 1
+end
+def peek()
+  let seen = step
+end
+for $i in 1..3
+  let step = i * 10
+  add $step
+end
+assert_equals $total 60
+assert_equals "$step" "$step"
+let label = upper("n=") + len(string(total))
+assert_equals $label N=2
+for $i in 1..1
+  let step = 5
+  peek
+end`)
+	want := []string{"line 6: undefined variable step (in peek called at line 18)"}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("errors = %q; want %q", errs, want)
+	}
+	if state.passed != 14 {
+		t.Errorf("passed %d; want 14", state.passed)
+	}
+	if _, ok := r.variables["local"]; ok {
+		t.Error("let in a function leaked into script variables")
+	}
+	if len(r.scopes) != 0 {
+		t.Errorf("%d scopes left open", len(r.scopes))
+	}
+}
+
+func TestTypedVariables(t *testing.T) {
+	var out strings.Builder
+	r := &Runner{
+		variables: map[string]any{"items": []any{"a", 2.0, map[string]any{"k": true}}},
+		Output:    &out,
+	}
+	_, errs := runBlocks(t, r, `let count = 0
+for $item in $items
+  let count = count + 1
+  if ${count == 3}
+    dump_vars
+  end
+end
+let name = "x"
+def show(v)
+  dump_vars
+end
+show 5`)
+	if len(errs) > 0 {
+		t.Fatalf("errors: %q", errs)
+	}
+	if r.variables["count"] != 3.0 {
+		t.Errorf("count = %#v; want 3", r.variables["count"])
+	}
+	want := `count (number) = 3
+item (object, local) = {"k":true}
+items (array) = ["a",2,{"k":true}]
+count (number) = 3
+items (array) = ["a",2,{"k":true}]
+name (string) = "x"
+v (number, local) = 5
+`
+	var dumps []string
+	for _, line := range strings.SplitAfter(out.String(), "\n") {
+		if strings.Contains(line, ") = ") {
+			dumps = append(dumps, line)
+		}
+	}
+	if got := strings.Join(dumps, ""); got != want {
+		t.Errorf("dump_vars:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -3,6 +3,8 @@ package scripting
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -21,8 +23,8 @@ func (r *Runner) handleCallTool(ctx context.Context, lineIdx int, line string) e
 	}
 	toolName := parts[1]
 	args := parts[2:]
-	fmt.Print(i18n.T(i18n.MsgExecuting, toolName, args))
-	return r.callToolPositional(ctx, toolName, args)
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgExecuting, toolName, args))
+	return r.callToolPositional(ctx, toolName, args, nil)
 }
 
 func (r *Runner) handleInputVar(lineIdx int, line string) error {
@@ -43,10 +45,10 @@ func (r *Runner) handleInputVarParts(lineIdx int, parts []string) error {
 		prompt = strings.Join(parts[2:], " ")
 		prompt = strings.Trim(prompt, "\"")
 	}
-	fmt.Print(prompt)
+	fmt.Fprint(r.out(), prompt)
 	scanner := bufio.NewScanner(os.Stdin)
 	if scanner.Scan() {
-		r.variables[varName] = scanner.Text()
+		r.setVar(varName, parseValue(scanner.Text()))
 	}
 	return nil
 }
@@ -71,8 +73,11 @@ func (r *Runner) handleSetVarParts(lineIdx int, varName, path string) error {
 	if err != nil {
 		return fmt.Errorf("line %d: failed to extract %q: %w", lineIdx+1, path, err)
 	}
-	r.variables[varName] = fmt.Sprintf("%v", val)
-	fmt.Print(i18n.T(i18n.MsgVariableSet, varName, r.variables[varName]))
+	if val == nil {
+		return fmt.Errorf("line %d: failed to extract %q: value is null", lineIdx+1, path)
+	}
+	r.setVar(varName, val)
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgVariableSet, varName, formatValue(val)))
 	return nil
 }
 
@@ -80,7 +85,11 @@ func (r *Runner) handleCallToolParts(ctx context.Context, lineIdx int, parts []s
 	if len(parts) < 2 {
 		return fmt.Errorf("line %d: call_tool expects at least a tool name", lineIdx+1)
 	}
-	return r.callToolPositional(ctx, parts[1], parts[2:])
+	values := r.argValuesFor(parts)
+	if values != nil {
+		values = values[2:]
+	}
+	return r.callToolPositional(ctx, parts[1], parts[2:], values)
 }
 
 func (r *Runner) handleTimeoutCommand(ctx context.Context, i int, parts []string) error {
@@ -105,20 +114,21 @@ func (r *Runner) handleExpectErrorCommand(ctx context.Context, i int, parts []st
 		return fmt.Errorf("line %d: expected error but command succeeded", i+1)
 	}
 	r.lastErrorCode = 0
-	if rpcErr, ok := err.(*client.RPCError); ok {
+	var rpcErr *client.RPCError
+	if errors.As(err, &rpcErr) {
 		r.lastErrorCode = rpcErr.Code
 	}
 	r.updateState(map[string]any{"error": err.Error(), "code": r.lastErrorCode}, err.Error())
-	fmt.Print(i18n.T(i18n.MsgExpectedError, err, r.lastErrorCode))
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgExpectedError, err, r.lastErrorCode))
 	return nil
 }
 
 func (r *Runner) handlePingCommand(ctx context.Context, i int) error {
-	fmt.Println("Ping...")
+	fmt.Fprintln(r.out(), "Ping...")
 	if err := r.session.Ping(ctx, &mcp.PingParams{}); err != nil {
 		return fmt.Errorf("line %d: ping failed: %w", i+1, err)
 	}
-	fmt.Println("Pong!")
+	fmt.Fprintln(r.out(), "Pong!")
 	return nil
 }
 
@@ -127,9 +137,103 @@ func (r *Runner) handleLoggingCommand(ctx context.Context, i int, parts []string
 		return fmt.Errorf("line %d: logging expects a level", i+1)
 	}
 	level := parts[1]
-	fmt.Printf("Setting server logging level to %s...\n", level)
+	fmt.Fprintf(r.out(), "Setting server logging level to %s...\n", level)
 	if err := r.session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: mcp.LoggingLevel(level)}); err != nil {
 		return fmt.Errorf("line %d: failed to set logging level: %w", i+1, err)
 	}
 	return nil
 }
+
+// handleRPCCommand sends an arbitrary JSON-RPC request: rpc <method> [params-json]
+func (r *Runner) handleRPCCommand(ctx context.Context, i int, parts []string) error {
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("line %d: rpc expects <method> [params-json]", i+1)
+	}
+	method := parts[1]
+	var params any
+	if len(parts) == 3 {
+		if err := json.Unmarshal([]byte(parts[2]), &params); err != nil {
+			return fmt.Errorf("line %d: invalid rpc params: %w", i+1, err)
+		}
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgExecuting, method, parts[2:]))
+
+	result, err := r.rpc.Call(ctx, method, params)
+	if err != nil {
+		var rpcErr *client.RPCError
+		if errors.As(err, &rpcErr) {
+			r.updateState(map[string]any{"error": rpcErrorMap(rpcErr)}, rpcErr.Message)
+		}
+		return fmt.Errorf("line %d: rpc %s failed: %w", i+1, method, err)
+	}
+
+	rawResponse := rpcResultMap(result)
+	r.updateState(rawResponse, extractTextFromRaw(rawResponse))
+	if !r.Raw {
+		fmt.Fprintf(r.out(), "Response:\n%s\n", r.lastResponse)
+	}
+	return nil
+}
+
+// rpcResultMap decodes a JSON-RPC result. Results that are not objects are
+// wrapped as {"result": value} so paths can still address them.
+func rpcResultMap(result json.RawMessage) map[string]any {
+	var m map[string]any
+	if err := json.Unmarshal(result, &m); err == nil && m != nil {
+		return m
+	}
+	var v any
+	_ = json.Unmarshal(result, &v)
+	return map[string]any{"result": v}
+}
+
+// rpcErrorMap returns the JSON-RPC error object of err.
+func rpcErrorMap(err *client.RPCError) map[string]any {
+	m := map[string]any{"code": err.Code, "message": err.Message}
+	if err.Data != nil {
+		m["data"] = err.Data
+	}
+	return m
+}
+
+// handleReadResourceCommand reads a resource: read_resource <uri>
+func (r *Runner) handleReadResourceCommand(ctx context.Context, i int, parts []string) error {
+	if len(parts) != 2 {
+		return fmt.Errorf("line %d: read_resource expects <uri>", i+1)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgExecuting, "read_resource", parts[1:]))
+	result, err := r.rpc.Call(ctx, "resources/read", map[string]any{"uri": parts[1]})
+	if err != nil {
+		return fmt.Errorf("line %d: failed to read resource %s: %w", i+1, parts[1], err)
+	}
+
+	rawResponse := rpcResultMap(result)
+	var text strings.Builder
+	if contents, ok := rawResponse["contents"].([]any); ok {
+		for _, c := range contents {
+			if cm, ok := c.(map[string]any); ok {
+				if t, ok := cm["text"].(string); ok {
+					fmt.Fprintf(r.out(), "Response: %s\n", t)
+					text.WriteString(t)
+				}
+			}
+		}
+	}
+	r.updateState(rawResponse, text.String())
+	return nil
+}
+
+// handleValidateCommand switches the validation of tool arguments against
+// the inputSchema or of tool results against the outputSchema:
+// validate_input|validate_output on|off
+func (r *Runner) handleValidateCommand(i int, parts []string) error {
+	if len(parts) != 2 || parts[1] != "on" && parts[1] != "off" {
+		return fmt.Errorf("line %d: %s expects on or off", i+1, parts[0])
+	}
+	if parts[0] == "validate_input" {
+		r.ValidateInput = parts[1] == "on"
+	} else {
+		r.ValidateOutput = parts[1] == "on"
+	}
+	return nil
+}
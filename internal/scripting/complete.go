@@ -0,0 +1,79 @@
+package scripting
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Complete returns completions for the last word of line: command names and
+// block keywords at the start of a command, tool names after call_tool and "key:" argument names
+// from the tool's input schema after that. head is the part of line before
+// the completed word.
+func Complete(line string, tools []*mcp.Tool) (head string, completions []string) {
+	start := strings.LastIndex(line, " ") + 1
+	head, word := line[:start], line[start:]
+	words := strings.Fields(head)
+
+	// Prefix commands wrap another command.
+	for len(words) > 0 {
+		if words[0] == "expect_error" {
+			words = words[1:]
+		} else if words[0] == "timeout" && len(words) >= 2 {
+			words = words[2:]
+		} else {
+			break
+		}
+	}
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		candidates = append(slices.Clone(commandNames), blockKeywords...)
+	case words[0] == "call_tool" && len(words) == 1:
+		for _, t := range tools {
+			candidates = append(candidates, t.Name)
+		}
+	case words[0] == "call_tool":
+		used := make(map[string]bool)
+		for _, w := range words[2:] {
+			if key, _, ok := strings.Cut(w, ":"); ok {
+				used[key] = true
+			}
+		}
+		for _, key := range toolArgumentKeys(tools, words[1]) {
+			if !used[key] {
+				candidates = append(candidates, key+":")
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			completions = append(completions, c)
+		}
+	}
+	sort.Strings(completions)
+	return head, completions
+}
+
+// toolArgumentKeys returns the property names of the tool's input schema.
+func toolArgumentKeys(tools []*mcp.Tool, name string) []string {
+	for _, t := range tools {
+		if t.Name != name {
+			continue
+		}
+		schema, ok := t.InputSchema.(map[string]any)
+		if !ok {
+			return nil
+		}
+		props, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+	return nil
+}
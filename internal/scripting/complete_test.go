@@ -0,0 +1,47 @@
+package scripting
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestComplete(t *testing.T) {
+	tools := []*mcp.Tool{
+		{Name: "echo", InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"message": map[string]any{"type": "string"}},
+		}},
+		{Name: "add", InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"a": map[string]any{"type": "number"},
+				"b": map[string]any{"type": "number"},
+			},
+		}},
+	}
+
+	tests := []struct {
+		line     string
+		wantHead string
+		want     []string
+	}{
+		{"assert_e", "", []string{"assert_empty", "assert_equals", "assert_error_code"}},
+		{"call_tool ", "call_tool ", []string{"add", "echo"}},
+		{"call_tool e", "call_tool ", []string{"echo"}},
+		{"call_tool add ", "call_tool add ", []string{"a:", "b:"}},
+		{"call_tool add a:1 ", "call_tool add a:1 ", []string{"b:"}},
+		{"expect_error call_tool ec", "expect_error call_tool ", []string{"echo"}},
+		{"timeout 500 call_tool echo m", "timeout 500 call_tool echo ", []string{"message:"}},
+		{"timeout 500 pi", "timeout 500 ", []string{"ping"}},
+		{"call_tool unknown ", "call_tool unknown ", nil},
+		{"assert_contains fo", "assert_contains ", nil},
+	}
+	for _, tt := range tests {
+		head, got := Complete(tt.line, tools)
+		if head != tt.wantHead || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %q, %q; want %q, %q", tt.line, head, got, tt.wantHead, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package scripting
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+)
+
+// contentTypes are the content types of tool results in the MCP spec.
+var contentTypes = []string{"text", "image", "audio", "resource", "resource_link"}
+
+// lastContent returns the content list of the last tool result.
+func (r *Runner) lastContent(lineIdx int, cmd string) ([]any, error) {
+	if r.lastRawMap == nil {
+		return nil, fmt.Errorf("line %d: %s: no previous response available", lineIdx+1, cmd)
+	}
+	content, ok := r.lastRawMap["content"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: %s: the last response is not a tool result", lineIdx+1, cmd)
+	}
+	return content, nil
+}
+
+// contentItem returns the content item at the index given as string.
+func (r *Runner) contentItem(lineIdx int, cmd, idx string) (map[string]any, error) {
+	content, err := r.lastContent(lineIdx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	i, err := strconv.Atoi(idx)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s: invalid index %s", lineIdx+1, cmd, idx)
+	}
+	if i < 0 || i >= len(content) {
+		return nil, assertionFailed(lineIdx, "no content at index %d, the result has %d items", i, len(content))
+	}
+	item, ok := content[i].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: %s: content %d is not an object", lineIdx+1, cmd, i)
+	}
+	return item, nil
+}
+
+// contentMimeType returns the MIME type of a content item. Embedded
+// resources carry it on the resource.
+func contentMimeType(item map[string]any) string {
+	if res, ok := item["resource"].(map[string]any); ok {
+		m, _ := res["mimeType"].(string)
+		return m
+	}
+	m, _ := item["mimeType"].(string)
+	return m
+}
+
+func (r *Runner) handleAssertIsErrorCommand(lineIdx int, parts []string, want bool) error {
+	if len(parts) != 1 {
+		return fmt.Errorf("line %d: %s expects no arguments", lineIdx+1, parts[0])
+	}
+	if _, err := r.lastContent(lineIdx, parts[0]); err != nil {
+		return err
+	}
+	isError, _ := r.lastRawMap["isError"].(bool)
+	switch {
+	case want && !isError:
+		return assertionFailed(lineIdx, "the tool result is not an error")
+	case !want && isError:
+		return assertionFailed(lineIdx, "the tool returned an error: %s", r.lastText)
+	}
+	desc := "the tool result is not an error"
+	if want {
+		desc = "the tool result is an error"
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, desc))
+	return nil
+}
+
+func (r *Runner) handleAssertContentCountCommand(lineIdx int, parts []string) error {
+	if len(parts) != 2 {
+		return fmt.Errorf("line %d: assert_content_count expects 1 argument (count)", lineIdx+1)
+	}
+	want, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("line %d: assert_content_count count must be an integer", lineIdx+1)
+	}
+	content, err := r.lastContent(lineIdx, parts[0])
+	if err != nil {
+		return err
+	}
+	if len(content) != want {
+		return assertionFailed(lineIdx, "the result has %d content items, expected %d", len(content), want)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("the result has %d content items", want)))
+	return nil
+}
+
+// handleAssertContentTypeCommand checks a content item: assert_content_type
+// <idx> <type>. type is a content type such as image or resource_link, or a
+// MIME type such as image/png.
+func (r *Runner) handleAssertContentTypeCommand(lineIdx int, parts []string) error {
+	if len(parts) != 3 {
+		return fmt.Errorf("line %d: assert_content_type expects <index> <type>", lineIdx+1)
+	}
+	want := parts[2]
+	if !strings.Contains(want, "/") && !slices.Contains(contentTypes, want) {
+		return fmt.Errorf("line %d: unknown content type %s (expected a MIME type or one of %s)", lineIdx+1, want, strings.Join(contentTypes, ", "))
+	}
+	item, err := r.contentItem(lineIdx, parts[0], parts[1])
+	if err != nil {
+		return err
+	}
+	got, _ := item["type"].(string)
+	if strings.Contains(want, "/") {
+		got = contentMimeType(item)
+	}
+	if got != want {
+		return assertionFailed(lineIdx, "content %s is %q, expected %q", parts[1], got, want)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("content %s is %s", parts[1], want)))
+	return nil
+}
+
+// handleSaveContentCommand writes a content item to a file: save_content
+// <idx> <file>. Images, audio and blob resources are decoded from base64.
+// Relative paths are resolved against the directory of the script.
+func (r *Runner) handleSaveContentCommand(lineIdx int, parts []string) error {
+	if len(parts) != 3 {
+		return fmt.Errorf("line %d: save_content expects <index> <file>", lineIdx+1)
+	}
+	item, err := r.contentItem(lineIdx, parts[0], parts[1])
+	if err != nil {
+		return err
+	}
+	data, err := contentData(item)
+	if err != nil {
+		return fmt.Errorf("line %d: save_content: content %s: %w", lineIdx+1, parts[1], err)
+	}
+	path := parts[2]
+	if !filepath.IsAbs(path) && r.dir != "" {
+		path = filepath.Join(r.dir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("line %d: save_content: %w", lineIdx+1, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("line %d: save_content: %w", lineIdx+1, err)
+	}
+	fmt.Fprint(r.out(), i18n.T(i18n.MsgContentSaved, parts[1], len(data), path))
+	return nil
+}
+
+// contentData returns the bytes of a content item.
+func contentData(item map[string]any) ([]byte, error) {
+	if res, ok := item["resource"].(map[string]any); ok {
+		item = res
+	}
+	if text, ok := item["text"].(string); ok {
+		return []byte(text), nil
+	}
+	for _, key := range []string{"data", "blob"} {
+		if s, ok := item[key].(string); ok {
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 %s: %w", key, err)
+			}
+			return data, nil
+		}
+	}
+	if uri, ok := item["uri"].(string); ok {
+		return nil, fmt.Errorf("resource link %s has no data, use read_resource to fetch it", uri)
+	}
+	return nil, fmt.Errorf("no data")
+}
@@ -0,0 +1,74 @@
+package scripting
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentAssertions(t *testing.T) {
+	dir := t.TempDir()
+	r := &Runner{Output: io.Discard, dir: dir}
+	r.updateState(map[string]any{
+		"content": []any{
+			map[string]any{"type": "text", "text": "chart"},
+			map[string]any{"type": "image", "mimeType": "image/png", "data": "iVBORw=="},
+			map[string]any{"type": "audio", "mimeType": "audio/wav", "data": "UklGRg=="},
+			map[string]any{"type": "resource", "resource": map[string]any{"uri": "file:///a.txt", "mimeType": "text/plain", "text": "hello"}},
+			map[string]any{"type": "resource_link", "uri": "file:///b.txt", "name": "b"},
+		},
+	}, "chart")
+
+	tests := []struct {
+		line string
+		want string // "" if the command succeeds, "fail" for a failed assertion, otherwise part of the error
+	}{
+		{"assert_not_error", ""},
+		{"assert_is_error", "fail"},
+		{"assert_content_count 5", ""},
+		{"assert_content_count 1", "fail"},
+		{"assert_content_type 0 text", ""},
+		{"assert_content_type 1 image", ""},
+		{"assert_content_type 1 image/png", ""},
+		{"assert_content_type 1 image/jpeg", "fail"},
+		{"assert_content_type 2 audio/wav", ""},
+		{"assert_content_type 3 text/plain", ""},
+		{"assert_content_type 4 resource_link", ""},
+		{"assert_content_type 9 text", "fail"},
+		{"assert_content_type 0 picture", "unknown content type"},
+		{"save_content 1 out/image.png", ""},
+		{"save_content 3 a.txt", ""},
+		{"save_content 4 b.txt", "use read_resource"},
+	}
+	for _, tt := range tests {
+		parts, _ := r.parseArgs(tt.line)
+		err := r.dispatchParts(context.Background(), 0, parts)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.line, err)
+		case tt.want == "fail" && (err == nil || !isAssertionFailure(err)):
+			t.Errorf("%s: got %v, want a failed assertion", tt.line, err)
+		case tt.want != "" && tt.want != "fail" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.line, err, tt.want)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "out", "image.png")); err != nil || string(data[1:4]) != "PNG" {
+		t.Errorf("saved image = %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("saved resource = %q, %v", data, err)
+	}
+
+	r.updateState(map[string]any{"content": []any{}, "isError": true}, "")
+	if err := r.handleAssertIsErrorCommand(0, []string{"assert_is_error"}, true); err != nil {
+		t.Errorf("assert_is_error on error result: %v", err)
+	}
+	r.updateState(map[string]any{"tools": []any{}}, "")
+	if err := r.handleAssertIsErrorCommand(0, []string{"assert_not_error"}, false); err == nil || !strings.Contains(err.Error(), "not a tool result") {
+		t.Errorf("assert_not_error on rpc result: %v", err)
+	}
+}
@@ -0,0 +1,760 @@
+package scripting
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expressions are used in ${...} and by let. They are parsed into a tree of
+// the following nodes and evaluated against the visible variables. Values
+// are decoded JSON: float64, string, bool, nil, []any and map[string]any.
+type exprNode interface{}
+
+type literalExpr struct{ value any }
+
+// varExpr is a variable reference, written as name or $name.
+type varExpr struct{ name string }
+
+type unaryExpr struct {
+	op string
+	x  exprNode
+}
+
+type binaryExpr struct {
+	op   string
+	x, y exprNode
+}
+
+type callExpr struct {
+	name string
+	args []exprNode
+}
+
+// indexExpr is x[index] or x.key, where key is a literal index.
+type indexExpr struct {
+	x, index exprNode
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // operator or identifier
+	value any    // number or string literal
+	pos   int
+}
+
+// tokenize splits an expression into tokens.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c >= '0' && c <= '9':
+			j := digitsEnd(src, i)
+			// A dot is a decimal point only if a digit follows, so items.0.id
+			// is an index.
+			if j+1 < len(src) && src[j] == '.' && src[j+1] >= '0' && src[j+1] <= '9' {
+				j = digitsEnd(src, j+1)
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				j++
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				j = digitsEnd(src, j)
+			}
+			f, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", src[i:j])
+			}
+			tokens = append(tokens, token{kind: tokNumber, value: f, pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			s, n, err := scanString(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, value: s, pos: i})
+			i += n
+		case c == '$' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			if c == '$' {
+				j++
+			}
+			n := identLen(src[j:])
+			if n == 0 {
+				return nil, fmt.Errorf("unexpected %q", "$")
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[j : j+n], pos: i})
+			i = j + n
+		default:
+			op := ""
+			for _, o := range []string{"==", "!=", "<=", ">=", "&&", "||"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" && strings.ContainsRune("+-*/%<>!(),.[]", rune(c)) {
+				op = string(c)
+			}
+			if op == "" {
+				if c == '=' {
+					return nil, fmt.Errorf("unexpected \"=\" (use == to compare)")
+				}
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func digitsEnd(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// scanString reads a quoted string literal at the start of s and returns its
+// value and length. \n, \t, \\ and an escaped quote are recognized.
+func scanString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// identLen returns the length of the identifier at the start of s.
+func identLen(s string) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || n > 0 && c >= '0' && c <= '9' {
+			n++
+			continue
+		}
+		break
+	}
+	return n
+}
+
+// exprParser is a recursive descent parser. From lowest to highest
+// precedence: || (or), && (and), comparisons, + -, * / %, unary - ! not,
+// and .key, [index] and function calls.
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+// parseExpr parses an expression.
+func parseExpr(src string) (exprNode, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	p := &exprParser{tokens: tokens}
+	x, err := p.or()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return x, nil
+}
+
+func (p *exprParser) peek() token { return p.tokens[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators or keywords.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return fmt.Errorf("expected %q at position %d", op, p.peek().pos+1)
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return fmt.Errorf("unexpected end")
+	}
+	return fmt.Errorf("unexpected token at position %d", t.pos+1)
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	x, err := p.and()
+	for err == nil {
+		if _, ok := p.accept("||", "or"); !ok {
+			break
+		}
+		var y exprNode
+		if y, err = p.and(); err == nil {
+			x = &binaryExpr{op: "||", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	x, err := p.comparison()
+	for err == nil {
+		if _, ok := p.accept("&&", "and"); !ok {
+			break
+		}
+		var y exprNode
+		if y, err = p.comparison(); err == nil {
+			x = &binaryExpr{op: "&&", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) comparison() (exprNode, error) {
+	x, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "contains")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: op, x: x, y: y}, nil
+}
+
+func (p *exprParser) additive() (exprNode, error) {
+	x, err := p.multiplicative()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			break
+		}
+		var y exprNode
+		if y, err = p.multiplicative(); err == nil {
+			x = &binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) multiplicative() (exprNode, error) {
+	x, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			break
+		}
+		var y exprNode
+		if y, err = p.unary(); err == nil {
+			x = &binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if op, ok := p.accept("-", "!", "not"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "not" {
+			op = "!"
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (exprNode, error) {
+	x, err := p.primary()
+	for err == nil {
+		if _, ok := p.accept("."); ok {
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokNumber {
+				return nil, fmt.Errorf("expected a key after \".\" at position %d", t.pos+1)
+			}
+			key := t.text
+			if t.kind == tokNumber {
+				key = formatValue(t.value)
+			}
+			x = &indexExpr{x: x, index: &literalExpr{key}}
+		} else if _, ok := p.accept("["); ok {
+			var index exprNode
+			if index, err = p.or(); err == nil {
+				err = p.expect("]")
+				x = &indexExpr{x: x, index: index}
+			}
+		} else {
+			break
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return nil, p.unexpected()
+	}
+	p.pos++
+	switch t.kind {
+	case tokNumber, tokString:
+		return &literalExpr{t.value}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalExpr{true}, nil
+		case "false":
+			return &literalExpr{false}, nil
+		case "null":
+			return &literalExpr{nil}, nil
+		}
+		if _, ok := p.accept("("); !ok {
+			return &varExpr{t.text}, nil
+		}
+		call := &callExpr{name: t.text}
+		if _, ok := exprFuncs[call.name]; !ok {
+			return nil, fmt.Errorf("unknown function %s", call.name)
+		}
+		if _, ok := p.accept(")"); ok {
+			return call, nil
+		}
+		for {
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		return call, p.expect(")")
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	}
+	p.pos--
+	return nil, p.unexpected()
+}
+
+// exprFuncs are the functions available in expressions.
+var exprFuncs = map[string]func(args []any) (any, error){
+	"len": func(args []any) (any, error) {
+		if err := argCount("len", args, 1, 1); err != nil {
+			return nil, err
+		}
+		n, ok := valueLen(args[0])
+		if !ok {
+			return nil, fmt.Errorf("len: %s has no length", jsonType(args[0]))
+		}
+		return float64(n), nil
+	},
+	"upper": stringFunc("upper", strings.ToUpper),
+	"lower": stringFunc("lower", strings.ToLower),
+	"trim":  stringFunc("trim", strings.TrimSpace),
+	"substr": func(args []any) (any, error) {
+		if err := argCount("substr", args, 2, 3); err != nil {
+			return nil, err
+		}
+		s := []rune(formatValue(args[0]))
+		start, err := toInt("substr", args[1])
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 {
+			start += len(s)
+		}
+		start = max(0, min(start, len(s)))
+		end := len(s)
+		if len(args) == 3 {
+			n, err := toInt("substr", args[2])
+			if err != nil {
+				return nil, err
+			}
+			end = max(start, min(start+n, len(s)))
+		}
+		return string(s[start:end]), nil
+	},
+	"json": func(args []any) (any, error) {
+		if err := argCount("json", args, 1, 1); err != nil {
+			return nil, err
+		}
+		b, err := encodeJSON(args[0], "")
+		if err != nil {
+			return nil, fmt.Errorf("json: %w", err)
+		}
+		return strings.TrimSuffix(string(b), "\n"), nil
+	},
+	"number": func(args []any) (any, error) {
+		if err := argCount("number", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return toNumber(args[0])
+	},
+	"string": func(args []any) (any, error) {
+		if err := argCount("string", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return formatValue(args[0]), nil
+	},
+}
+
+func stringFunc(name string, f func(string) string) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if err := argCount(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		return f(formatValue(args[0])), nil
+	}
+}
+
+func argCount(name string, args []any, lo, hi int) error {
+	if len(args) < lo || len(args) > hi {
+		if lo == hi {
+			return fmt.Errorf("%s expects %d arguments, got %d", name, lo, len(args))
+		}
+		return fmt.Errorf("%s expects %d to %d arguments, got %d", name, lo, hi, len(args))
+	}
+	return nil
+}
+
+func toNumber(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a number", compactJSON(v))
+}
+
+func toInt(name string, v any) (int, error) {
+	f, err := toNumber(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("%s: %v is not an integer", name, f)
+	}
+	return int(f), nil
+}
+
+// truthy reports whether v counts as true in a condition: everything except
+// null, false, 0 and the strings "", "false", "0" and "null".
+func truthy(v any) bool {
+	switch formatValue(v) {
+	case "", "false", "0", "null":
+		return false
+	}
+	return true
+}
+
+// evalExpr evaluates x. lookup returns the value of a variable.
+func evalExpr(x exprNode, lookup func(name string) (any, bool)) (any, error) {
+	switch x := x.(type) {
+	case *literalExpr:
+		return x.value, nil
+	case *varExpr:
+		v, ok := lookup(x.name)
+		if !ok {
+			return nil, fmt.Errorf("undefined variable %s", x.name)
+		}
+		return v, nil
+	case *unaryExpr:
+		v, err := evalExpr(x.x, lookup)
+		if err != nil {
+			return nil, err
+		}
+		if x.op == "!" {
+			return !truthy(v), nil
+		}
+		f, err := toNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		return -f, nil
+	case *binaryExpr:
+		return evalBinary(x, lookup)
+	case *callExpr:
+		args := make([]any, len(x.args))
+		for i, a := range x.args {
+			v, err := evalExpr(a, lookup)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return exprFuncs[x.name](args)
+	case *indexExpr:
+		v, err := evalExpr(x.x, lookup)
+		if err != nil {
+			return nil, err
+		}
+		index, err := evalExpr(x.index, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return indexValue(v, index)
+	}
+	return nil, fmt.Errorf("invalid expression")
+}
+
+func evalBinary(x *binaryExpr, lookup func(string) (any, bool)) (any, error) {
+	a, err := evalExpr(x.x, lookup)
+	if err != nil {
+		return nil, err
+	}
+	// && and || only evaluate the right side if needed.
+	switch x.op {
+	case "&&":
+		if !truthy(a) {
+			return false, nil
+		}
+	case "||":
+		if truthy(a) {
+			return true, nil
+		}
+	}
+	b, err := evalExpr(x.y, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "&&", "||":
+		return truthy(b), nil
+	case "==":
+		return valuesEqual(a, b), nil
+	case "!=":
+		return !valuesEqual(a, b), nil
+	case "contains":
+		return valueContains(a, b), nil
+	case "<", "<=", ">", ">=":
+		return compareOrdered(x.op, a, b)
+	case "+":
+		fa, aNum := a.(float64)
+		fb, bNum := b.(float64)
+		if aNum && bNum {
+			return fa + fb, nil
+		}
+		_, aStr := a.(string)
+		_, bStr := b.(string)
+		if aStr || bStr {
+			return formatValue(a) + formatValue(b), nil
+		}
+		return nil, fmt.Errorf("cannot add %s and %s", jsonType(a), jsonType(b))
+	}
+
+	fa, err := toNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	fb, err := toNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "-":
+		return fa - fb, nil
+	case "*":
+		return fa * fb, nil
+	case "/":
+		if fb == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return fa / fb, nil
+	case "%":
+		if fb == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(fa, fb), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", x.op)
+}
+
+// valuesEqual compares numbers numerically and other values by their text,
+// so "5" == 5 and objects are equal if their JSON is.
+func valuesEqual(a, b any) bool {
+	fa, errA := toNumber(a)
+	fb, errB := toNumber(b)
+	if errA == nil && errB == nil {
+		return fa == fb
+	}
+	return formatValue(a) == formatValue(b)
+}
+
+// valueContains reports whether a string contains a substring, an array an
+// element or an object a key.
+func valueContains(a, b any) bool {
+	switch a := a.(type) {
+	case []any:
+		for _, item := range a {
+			if valuesEqual(item, b) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		_, ok := a[formatValue(b)]
+		return ok
+	}
+	return strings.Contains(formatValue(a), formatValue(b))
+}
+
+// compareOrdered compares two numbers or two strings.
+func compareOrdered(op string, a, b any) (bool, error) {
+	var c int
+	fa, errA := toNumber(a)
+	fb, errB := toNumber(b)
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	switch {
+	case errA == nil && errB == nil:
+		c = cmpFloat(fa, fb)
+	case aStr && bStr:
+		c = strings.Compare(sa, sb)
+	default:
+		return false, fmt.Errorf("cannot compare %s %s %s", compactJSON(a), op, compactJSON(b))
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// indexValue returns an array item (negative indexes count from the end) or
+// an object value.
+func indexValue(v, index any) (any, error) {
+	switch c := v.(type) {
+	case []any:
+		i, err := toInt("index", index)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			i += len(c)
+		}
+		if i < 0 || i >= len(c) {
+			return nil, fmt.Errorf("index %s out of range (length %d)", formatValue(index), len(c))
+		}
+		return c[i], nil
+	case map[string]any:
+		key := formatValue(index)
+		val, ok := c[key]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", key)
+		}
+		return val, nil
+	}
+	return nil, fmt.Errorf("cannot index %s", jsonType(v))
+}
+
+// exprVars returns the variables referenced by x.
+func exprVars(x exprNode) []string {
+	var names []string
+	var walk func(exprNode)
+	walk = func(x exprNode) {
+		switch x := x.(type) {
+		case *varExpr:
+			names = append(names, x.name)
+		case *unaryExpr:
+			walk(x.x)
+		case *binaryExpr:
+			walk(x.x)
+			walk(x.y)
+		case *callExpr:
+			for _, a := range x.args {
+				walk(a)
+			}
+		case *indexExpr:
+			walk(x.x)
+			walk(x.index)
+		}
+	}
+	walk(x)
+	return names
+}
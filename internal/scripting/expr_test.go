@@ -0,0 +1,120 @@
+package scripting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	vars := map[string]any{
+		"n":    3.0,
+		"s":    "Hello",
+		"list": []any{1.0, "two", 3.0},
+		"obj":  map[string]any{"id": 7.0, "tags": []any{"a"}},
+	}
+	lookup := func(name string) (any, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 / 4", "2.5"},
+		{"10 % 4", "2"},
+		{"-n + 1", "-2"},
+		{"1000000 * 2", "2000000"},
+		{"$n * 2", "6"},
+		{"s + ' ' + n", "Hello 3"},
+		{"upper(s)", "HELLO"},
+		{"lower(s)", "hello"},
+		{"trim('  x ')", "x"},
+		{"len(s)", "5"},
+		{"len(list)", "3"},
+		{"len(obj)", "2"},
+		{"substr(s, 1, 3)", "ell"},
+		{"substr(s, -2)", "lo"},
+		{"substr(s, 3, 10)", "lo"},
+		{"json(obj)", `{"id":7,"tags":["a"]}`},
+		{"json(s)", `"Hello"`},
+		{"number('4.5') + 1", "5.5"},
+		{"string(n) + 1", "31"},
+		{"obj.id", "7"},
+		{"obj.tags[0]", "a"},
+		{"list[-1]", "3"},
+		{"list.1", "two"},
+		{"obj['id'] == 7", "true"},
+		{"n > 2 && s == 'Hello'", "true"},
+		{"n < 2 || !true", "false"},
+		{"not (n >= 3)", "false"},
+		{"'abc' < 'abd'", "true"},
+		{"'5' == 5", "true"},
+		{"list contains 'two'", "true"},
+		{"obj contains 'name'", "false"},
+		{"s contains 'ell'", "true"},
+		{"missing == 1 && false", "false"},
+		{"null", "null"},
+		{`"a\"b"`, `a"b`},
+	}
+	for _, tt := range tests {
+		x, err := parseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if tt.expr == "missing == 1 && false" {
+			// The right side is not evaluated, the left side is.
+			if _, err := evalExpr(x, lookup); err == nil || !strings.Contains(err.Error(), "undefined variable missing") {
+				t.Errorf("%s: got %v, want undefined variable", tt.expr, err)
+			}
+			continue
+		}
+		v, err := evalExpr(x, lookup)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := formatValue(v); got != tt.want {
+			t.Errorf("%s = %s; want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	errors := []struct {
+		expr string
+		want string
+	}{
+		{"1 +", "unexpected end"},
+		{"a = 1", "use == to compare"},
+		{"foo(1)", "unknown function foo"},
+		{"'abc", "unterminated string"},
+		{"(1", `expected ")"`},
+	}
+	for _, tt := range errors {
+		if _, err := parseExpr(tt.expr); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseExpr(%q) = %v; want %q", tt.expr, err, tt.want)
+		}
+	}
+	evalErrors := []struct {
+		expr string
+		want string
+	}{
+		{"1 / 0", "division by zero"},
+		{"s * 2", "is not a number"},
+		{"list[5]", "out of range"},
+		{"obj.name", `key "name" not found`},
+		{"len(n)", "has no length"},
+		{"substr(s)", "expects 2 to 3 arguments"},
+		{"list < 1", "cannot compare"},
+	}
+	for _, tt := range evalErrors {
+		x, err := parseExpr(tt.expr)
+		if err == nil {
+			_, err = evalExpr(x, lookup)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v; want %q", tt.expr, err, tt.want)
+		}
+	}
+}
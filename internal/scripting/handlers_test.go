@@ -1,6 +1,9 @@
 package scripting
 
 import (
+	"context"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -68,7 +71,7 @@ func TestAssertionHandlers(t *testing.T) {
 	})
 
 	t.Run("handleSetVar", func(t *testing.T) {
-		r.variables = make(map[string]string)
+		r.variables = make(map[string]any)
 		r.lastResponse = `{"data": {"value": 42}, "status": "ok"}`
 		r.lastRawMap = map[string]any{"dummy": "data"} // Ensure not nil
 
@@ -91,8 +94,8 @@ func TestAssertionHandlers(t *testing.T) {
 		if err != nil {
 			t.Errorf("expected no error, got %v", err)
 		}
-		if r.variables["deep_val"] != "42" {
-			t.Errorf("expected 42, got %s", r.variables["deep_val"])
+		if r.variables["deep_val"] != 42.0 {
+			t.Errorf("expected 42, got %v", r.variables["deep_val"])
 		}
 
 		// Test invalid path
@@ -122,4 +125,138 @@ func TestAssertionHandlers(t *testing.T) {
 			t.Errorf("expected error for too long string, got nil")
 		}
 	})
+	t.Run("handleAssertPathCommand", func(t *testing.T) {
+		r.lastRawMap = map[string]any{"structuredContent": map[string]any{
+			"items": []any{map[string]any{"id": 1.0, "ok": true}, map[string]any{"id": 2.0, "ok": false}},
+		}}
+		for _, parts := range [][]string{
+			{"assert_path", "$.items[0].id", "==", "1"},
+			{"assert_path", "$.items[*].id", "==", "[1,2]"},
+			{"assert_path", "$.items[?(@.ok==true)].id", "==", "[1]"},
+			{"assert_path", "$..id", "contains", "2"},
+			{"assert_path", "structuredContent.items.1.id", ">=", "2"},
+		} {
+			if err := r.handleAssertPathCommand(0, parts); err != nil {
+				t.Errorf("%v: expected no error, got %v", parts, err)
+			}
+		}
+
+		err := r.handleAssertPathCommand(0, []string{"assert_path", "$.items[1].id", "<", "2"})
+		if err == nil || !strings.Contains(err.Error(), `$.items[1].id is "2", expected < "2"`) {
+			t.Errorf("expected failed comparison, got %v", err)
+		}
+		err = r.handleAssertPathCommand(0, []string{"assert_path", "$.items[0].name", "==", "x"})
+		if err == nil || !strings.Contains(err.Error(), `key "name" not found at $.items[0]`) {
+			t.Errorf("expected missing key error, got %v", err)
+		}
+	})
+
+	t.Run("rpcResultMap", func(t *testing.T) {
+		m := rpcResultMap([]byte(`{"tools":[{"name":"echo"}]}`))
+		r.lastRawMap = m
+		if val, err := r.extractValue("tools.0.name"); err != nil || val != "echo" {
+			t.Errorf("expected echo, got %v (%v)", val, err)
+		}
+
+		m = rpcResultMap([]byte(`[1,2]`))
+		if list, ok := m["result"].([]any); !ok || len(list) != 2 {
+			t.Errorf("expected non-object result wrapped under \"result\", got %v", m)
+		}
+	})
+}
+
+func TestAssertTypedOperands(t *testing.T) {
+	r := &Runner{Output: io.Discard, variables: map[string]any{"code": "42", "n": 42.0, "list": []any{"a"}}}
+	_, errs := runBlocks(t, r, `assert_type $code string
+assert_type ${code} string
+assert_type $n integer
+assert_len $code 2
+assert_type $list array
+not assert_empty $list
+assert_type code=$code string`)
+	if len(errs) > 0 {
+		t.Errorf("errors: %q", errs)
+	}
+}
+
+func TestValueAssertions(t *testing.T) {
+	r := &Runner{Output: io.Discard, variables: map[string]any{}}
+	for _, line := range []string{"assert_empty", "not assert_empty", "assert_len 0", "assert_matches .*", "assert_type null"} {
+		parts, _ := r.parseArgs(line)
+		if err := r.dispatchParts(context.Background(), 0, parts); err == nil || !strings.Contains(err.Error(), "no previous response") {
+			t.Errorf("%s without a response: got %v", line, err)
+		}
+	}
+
+	r.updateState(map[string]any{
+		"content":           []any{map[string]any{"type": "text", "text": "order 42 created"}},
+		"structuredContent": map[string]any{"id": 42.0, "items": []any{"a", "b"}},
+	}, "order 42 created")
+
+	tests := []struct {
+		line string
+		want string // "" if the assertion passes, "fail" for a failed assertion, otherwise part of the error
+	}{
+		{`assert_matches "order \d+"`, ""},
+		{`assert_matches "^created"`, "fail"},
+		{`assert_matches abc123 "^[a-z]+\d+$"`, ""},
+		{`assert_matches x "("`, "invalid regular expression"},
+		{`assert_lt 3 5`, ""},
+		{`assert_lt 5 5`, "fail"},
+		{`assert_lte 5 5`, ""},
+		{`assert_gte 4 5`, "fail"},
+		{`assert_gte abc 5`, "fail"},
+		{`assert_between 5 1 10`, ""},
+		{`assert_between 11 1 10`, "fail"},
+		{`assert_between 5 a 10`, "must be numbers"},
+		{`assert_type object`, ""},
+		{`assert_type 42 integer`, ""},
+		{`assert_type 4.5 integer`, "fail"},
+		{`assert_type true boolean`, ""},
+		{`assert_type null null`, ""},
+		{`assert_type hello string`, ""},
+		{`assert_type "[1]" array`, ""},
+		{`assert_type hello float`, "unknown type"},
+		{`assert_len 2`, ""},
+		{`assert_len 3`, "fail"},
+		{`assert_len "[1,2,3]" 3`, ""},
+		{`assert_len '{"a":1}' 1`, ""},
+		{`assert_len héllo 5`, ""},
+		{`assert_len 12345 5`, ""},
+		{`assert_empty`, "fail"},
+		{`assert_empty ""`, ""},
+		{`assert_empty "[]"`, ""},
+		{`assert_empty "{}"`, ""},
+		{`assert_empty x`, "fail"},
+		{`not assert_contains "deleted"`, ""},
+		{`not assert_contains "created"`, "fail"},
+		{`not assert_between 5 a 10`, "must be numbers"},
+		{`not ping`, "expects an assert_ command"},
+		// Only failed assertions count, not errors that mention one.
+		{`not assert_schema "assertion failed: x.json"`, "no such file"},
+	}
+	for _, tt := range tests {
+		parts, err := r.parseArgs(tt.line)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", tt.line, err)
+		}
+		err = r.dispatchParts(context.Background(), 0, parts)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.line, err)
+		case tt.want == "fail" && (err == nil || !isAssertionFailure(err)):
+			t.Errorf("%s: got %v, want a failed assertion", tt.line, err)
+		case tt.want != "" && tt.want != "fail" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.line, err, tt.want)
+		}
+	}
+
+	// An array as structuredContent is the value of the last response.
+	r.updateState(map[string]any{"structuredContent": []any{1.0, 2.0}}, "")
+	for _, line := range []string{"assert_len 2", "assert_type array", "not assert_empty"} {
+		parts, _ := r.parseArgs(line)
+		if err := r.dispatchParts(context.Background(), 0, parts); err != nil {
+			t.Errorf("%s: %v", line, err)
+		}
+	}
 }
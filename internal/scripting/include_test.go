@@ -0,0 +1,114 @@
+package scripting
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeScripts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"main.mcp":        "include \"lib/common.mcp\"\nfor $x in 1..2\n  include \"lib/check.mcp\"\nend\nexpect $user admin",
+		"lib/common.mcp":  "include ../shared.mcp\ndef expect(got, want)\n  assert_equals $got $want\nend",
+		"lib/check.mcp":   "assert_equals $user guest",
+		"shared.mcp":      "assert_number 1",
+		"cycle/a.mcp":     "include b.mcp",
+		"cycle/b.mcp":     "ping\ninclude a.mcp",
+		"broken/main.mcp": "ping\ninclude lib.mcp",
+		"broken/lib.mcp":  "ping\nend",
+	})
+	r := &Runner{variables: map[string]any{"user": "guest"}}
+
+	result, err := r.RunFile(context.Background(), filepath.Join(dir, "main.mcp"), "")
+	if err != nil {
+		t.Fatalf("RunFile: %v", err)
+	}
+	if result.Executed != 4 || result.Failed != 1 {
+		t.Errorf("result = %+v; want 4 executed, 1 failed", result)
+	}
+
+	var errs []string
+	state := &runState{onError: func(err error) { errs = append(errs, err.Error()) }}
+	nodes, err := r.parse([]string{`include "lib/common.mcp"`, "expect a b"}, 0, source{dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.define(nodes)
+	r.exec(context.Background(), nodes, state)
+	want := `lib/common.mcp: line 3: assertion failed: "a" != "b" (in expect called at line 2)`
+	if len(errs) != 1 || !strings.HasSuffix(errs[0], want) {
+		t.Errorf("errors = %q; want suffix %q", errs, want)
+	}
+
+	_, err = r.RunFile(context.Background(), filepath.Join(dir, "cycle/a.mcp"), "")
+	if err == nil || !strings.Contains(err.Error(), "b.mcp: line 2: include cycle") {
+		t.Errorf("cycle error = %v", err)
+	}
+	_, err = r.RunFile(context.Background(), filepath.Join(dir, "broken/main.mcp"), "")
+	if err == nil || !strings.HasSuffix(err.Error(), "lib.mcp: line 2: end without block") {
+		t.Errorf("include parse error = %v", err)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	r := &Runner{variables: map[string]any{}}
+	script := `teardown
+  assert_equals first-teardown x
+end
+assert_equals body x
+setup
+  assert_equals setup x
+end
+teardown
+  assert_equals second-teardown x
+end`
+	var errs []string
+	nodes, err := r.parse(strings.Split(script, "\n"), 0, source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup, body, teardown := splitFixtures(nodes)
+	if len(setup) != 1 || len(body) != 1 || len(teardown) != 2 || teardown[0].position().line() != 7 {
+		t.Fatalf("splitFixtures = %d, %d, %d nodes", len(setup), len(body), len(teardown))
+	}
+
+	state := &runState{onError: func(err error) { errs = append(errs, err.Error()) }}
+	for _, part := range [][]node{setup, body, teardown} {
+		r.exec(context.Background(), part, state)
+	}
+	order := []string{"setup", "body", "second-teardown", "first-teardown"}
+	for i, want := range order {
+		if !strings.Contains(errs[i], want) {
+			t.Errorf("error %d = %q; want %s", i, errs[i], want)
+		}
+	}
+
+	result, err := r.Run(context.Background(), script, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Executed != 3 || result.Failed != 3 {
+		t.Errorf("result = %+v; want body skipped after failed setup", result)
+	}
+
+	if _, err := r.parse([]string{"if 1", "setup", "end", "end"}, 0, source{}); err == nil {
+		t.Error("expected error for nested setup")
+	}
+}
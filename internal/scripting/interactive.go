@@ -0,0 +1,73 @@
+package scripting
+
+import (
+	"context"
+	"errors"
+)
+
+// Interactive executes script input one line at a time against a Runner,
+// keeping variables, functions and the last response between calls. Heredocs
+// and blocks span several lines and run once they are complete. Setup
+// sections run immediately, teardown sections when the Interactive is closed.
+type Interactive struct {
+	runner   *Runner
+	pending  []string
+	heredoc  bool
+	lineIdx  int
+	teardown []node
+}
+
+// NewInteractive creates an Interactive for runner.
+func NewInteractive(runner *Runner) *Interactive {
+	return &Interactive{runner: runner}
+}
+
+// Pending reports whether lines of an unfinished heredoc or block are being
+// collected.
+func (in *Interactive) Pending() bool {
+	return len(in.pending) > 0
+}
+
+// InHeredoc reports whether the lines of a heredoc are being collected.
+func (in *Interactive) InHeredoc() bool {
+	return in.heredoc
+}
+
+// Exec adds one line to the input. Once the input forms complete statements
+// they are executed; executed is false for blank lines, comments and
+// unfinished input. The errors of all failed commands are joined.
+func (in *Interactive) Exec(ctx context.Context, line string) (executed bool, err error) {
+	in.pending = append(in.pending, line)
+	nodes, err := in.runner.parse(in.pending, in.lineIdx, source{dir: "."})
+	var ie *includeError
+	if !errors.As(err, &ie) {
+		in.heredoc = errors.Is(err, errUnclosedHeredoc)
+		if in.heredoc || errors.Is(err, errUnclosedBlock) {
+			return false, nil
+		}
+	}
+	in.lineIdx += len(in.pending)
+	in.pending = nil
+	if err != nil {
+		return false, err
+	}
+
+	in.runner.define(nodes)
+	setup, body, teardown := splitFixtures(nodes)
+	in.teardown = append(teardown, in.teardown...)
+	var errs []error
+	state := &runState{onError: func(err error) { errs = append(errs, err) }, onTest: in.runner.printTestCase}
+	in.runner.exec(ctx, setup, state)
+	in.runner.exec(ctx, body, state)
+	return len(nodes) > 0, errors.Join(errs...)
+}
+
+// Close runs the teardown sections entered so far, most recent first.
+func (in *Interactive) Close(ctx context.Context) error {
+	teardown := in.teardown
+	in.teardown = nil
+	var errs []error
+	state := &runState{onError: func(err error) { errs = append(errs, err) }}
+	in.runner.exec(context.WithoutCancel(ctx), teardown, state)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,605 @@
+package scripting
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stepKind is the selector of a JSONPath step.
+type stepKind int
+
+const (
+	stepKey      stepKind = iota // .name or ['name']
+	stepIndex                    // [n]
+	stepWildcard                 // .* or [*]
+	stepSlice                    // [start:end]
+	stepFilter                   // [?(@.x == 1)]
+)
+
+// pathStep is one selector of a JSONPath expression. recursive is set for
+// selectors after "..", which apply to the value and all its descendants.
+type pathStep struct {
+	kind       stepKind
+	key        string
+	index      int
+	start, end *int
+	filter     *pathFilter
+	recursive  bool
+}
+
+// pathFilter is the condition of a filter selector: comparisons of a path
+// relative to the current item ("@") with a literal, or the existence of such
+// a path, joined by && or ||. && binds tighter than ||.
+type pathFilter struct {
+	any [][]filterTerm // disjunction of conjunctions
+}
+
+type filterTerm struct {
+	path  *jsonPath
+	op    string // "" for an existence check
+	value string
+}
+
+// jsonPath is a compiled JSONPath expression. Paths without a leading "$" are
+// dot paths relative to the root, so "result.items.0" still works; numeric
+// keys index arrays.
+type jsonPath struct {
+	expr  string
+	steps []pathStep
+}
+
+// compilePath parses a JSONPath expression.
+func compilePath(expr string) (*jsonPath, error) {
+	p := &jsonPath{expr: expr}
+	s := strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(s, "$"), strings.HasPrefix(s, "@"):
+		s = s[1:]
+	case s != "":
+		s = "." + s
+	}
+
+	for s != "" {
+		recursive := false
+		switch {
+		case strings.HasPrefix(s, ".."):
+			recursive = true
+			s = s[2:]
+			if !strings.HasPrefix(s, "[") {
+				s = "." + s
+			}
+		case !strings.HasPrefix(s, ".") && !strings.HasPrefix(s, "["):
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", expr, s)
+		}
+
+		var step pathStep
+		if strings.HasPrefix(s, "[") {
+			end := closingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			var err error
+			if step, err = parseBracket(s[1:end]); err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", expr, err)
+			}
+			s = s[end+1:]
+		} else {
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid path %q: empty key", expr)
+			case "*":
+				step.kind = stepWildcard
+			default:
+				step = pathStep{kind: stepKey, key: name}
+			}
+		}
+		step.recursive = recursive
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the "]" closing the "[" at s[0],
+// skipping quoted strings and nested brackets.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseBracket parses the content of a bracket selector.
+func parseBracket(s string) (pathStep, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "*":
+		return pathStep{kind: stepWildcard}, nil
+	case strings.HasPrefix(s, "?"):
+		f, err := parseFilter(s[1:])
+		return pathStep{kind: stepFilter, filter: f}, err
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return pathStep{kind: stepKey, key: s[1 : len(s)-1]}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return pathStep{kind: stepIndex, index: n}, nil
+	}
+	if from, to, ok := strings.Cut(s, ":"); ok {
+		step := pathStep{kind: stepSlice}
+		for _, b := range []struct {
+			text string
+			dst  **int
+		}{{from, &step.start}, {to, &step.end}} {
+			if t := strings.TrimSpace(b.text); t != "" {
+				n, err := strconv.Atoi(t)
+				if err != nil {
+					return pathStep{}, fmt.Errorf("invalid slice [%s]", s)
+				}
+				*b.dst = &n
+			}
+		}
+		return step, nil
+	}
+	if s == "" {
+		return pathStep{}, fmt.Errorf("empty []")
+	}
+	// An unquoted key, e.g. after the shell-style quotes were removed.
+	return pathStep{kind: stepKey, key: s}, nil
+}
+
+// parseFilter parses "(@.x op value && ...)".
+func parseFilter(s string) (*pathFilter, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("filter must be ?(...)")
+	}
+	s = s[1 : len(s)-1]
+	f := &pathFilter{}
+	for _, alt := range splitUnquoted(s, "||") {
+		var all []filterTerm
+		for _, cond := range splitUnquoted(alt, "&&") {
+			t, err := parseFilterTerm(strings.TrimSpace(cond))
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, t)
+		}
+		f.any = append(f.any, all)
+	}
+	return f, nil
+}
+
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">", " contains "}
+
+func parseFilterTerm(s string) (filterTerm, error) {
+	if !strings.HasPrefix(s, "@") {
+		return filterTerm{}, fmt.Errorf("filter condition %q must start with @", s)
+	}
+	var t filterTerm
+	left := s
+	for _, op := range filterOps {
+		if i := indexUnquoted(s, op); i >= 0 {
+			left = strings.TrimSpace(s[:i])
+			t.op = strings.TrimSpace(op)
+			t.value = strings.TrimSpace(s[i+len(op):])
+			if len(t.value) >= 2 && (t.value[0] == '\'' || t.value[0] == '"') && t.value[len(t.value)-1] == t.value[0] {
+				t.value = t.value[1 : len(t.value)-1]
+			}
+			break
+		}
+	}
+	p, err := compilePath(left)
+	if err != nil {
+		return filterTerm{}, err
+	}
+	t.path = p
+	return t, nil
+}
+
+// indexUnquoted returns the index of the first sep in s outside quoted
+// strings, or -1.
+func indexUnquoted(s, sep string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitUnquoted splits s at every sep outside quoted strings.
+func splitUnquoted(s, sep string) []string {
+	var parts []string
+	for {
+		i := indexUnquoted(s, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+len(sep):]
+	}
+}
+
+// definite reports whether the path selects at most one value.
+func (p *jsonPath) definite() bool {
+	for _, s := range p.steps {
+		if s.recursive || s.kind == stepWildcard || s.kind == stepSlice || s.kind == stepFilter {
+			return false
+		}
+	}
+	return true
+}
+
+// pathError is returned by get for a path that does not match. depth is the
+// number of steps that matched.
+type pathError struct {
+	depth int
+	msg   string
+}
+
+func (e *pathError) Error() string { return e.msg }
+
+// get returns the single value of a definite path. The error names the
+// first step that did not match.
+func (p *jsonPath) get(root any) (any, error) {
+	current := root
+	at := "$"
+	for depth, s := range p.steps {
+		matches := s.apply(current)
+		if len(matches) == 0 {
+			var msg string
+			switch current.(type) {
+			case map[string]any:
+				if s.kind == stepIndex {
+					msg = fmt.Sprintf("cannot index object at %s with [%d]", at, s.index)
+				} else {
+					msg = fmt.Sprintf("key %q not found at %s", s.key, at)
+				}
+			case []any:
+				if s.kind == stepKey {
+					msg = fmt.Sprintf("invalid array index %q at %s", s.key, at)
+				} else {
+					msg = fmt.Sprintf("array index %d out of range at %s", s.index, at)
+				}
+			default:
+				msg = fmt.Sprintf("cannot navigate into %s value at %s", jsonType(current), at)
+			}
+			return nil, &pathError{depth: depth, msg: msg}
+		}
+		current = matches[0]
+		at += s.String()
+	}
+	return current, nil
+}
+
+// query returns all values selected by the path.
+func (p *jsonPath) query(root any) []any {
+	current := []any{root}
+	for _, s := range p.steps {
+		var next []any
+		for _, v := range current {
+			if s.recursive {
+				for _, d := range descendants(v) {
+					next = append(next, s.apply(d)...)
+				}
+			} else {
+				next = append(next, s.apply(v)...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func (s pathStep) String() string {
+	prefix := ""
+	if s.recursive {
+		prefix = ".."
+	}
+	switch s.kind {
+	case stepIndex:
+		return fmt.Sprintf("%s[%d]", prefix, s.index)
+	case stepWildcard:
+		return prefix + "[*]"
+	case stepSlice, stepFilter:
+		return prefix + "[...]"
+	}
+	if isIdentifier(s.key) {
+		if prefix == "" {
+			prefix = "."
+		}
+		return prefix + s.key
+	}
+	return fmt.Sprintf("%s[%q]", prefix, s.key)
+}
+
+// apply returns the values the step selects from v.
+func (s pathStep) apply(v any) []any {
+	switch s.kind {
+	case stepKey:
+		switch v := v.(type) {
+		case map[string]any:
+			if val, ok := v[s.key]; ok {
+				return []any{val}
+			}
+		case []any:
+			// Dot paths address array items by number.
+			if i, err := strconv.Atoi(s.key); err == nil && i >= 0 && i < len(v) {
+				return []any{v[i]}
+			}
+		}
+	case stepIndex:
+		if arr, ok := v.([]any); ok {
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				return []any{arr[i]}
+			}
+		}
+	case stepWildcard:
+		return children(v)
+	case stepSlice:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		start, end := 0, len(arr)
+		if s.start != nil {
+			start = clampIndex(*s.start, len(arr))
+		}
+		if s.end != nil {
+			end = clampIndex(*s.end, len(arr))
+		}
+		if start < end {
+			return arr[start:end]
+		}
+	case stepFilter:
+		var out []any
+		for _, c := range children(v) {
+			if s.filter.match(c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
+
+// children returns the items of an array or the values of an object, sorted
+// by key.
+func children(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		keys := sortedKeys(v)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = v[k]
+		}
+		return out
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// descendants returns v and all values nested in it, depth first.
+func descendants(v any) []any {
+	out := []any{v}
+	for _, c := range children(v) {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
+
+func (f *pathFilter) match(v any) bool {
+	for _, all := range f.any {
+		ok := true
+		for _, t := range all {
+			if !t.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t filterTerm) match(v any) bool {
+	matches := t.path.query(v)
+	if t.op == "" {
+		return len(matches) > 0
+	}
+	for _, m := range matches {
+		if ok, err := compareValues(formatValue(m), t.op, t.value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// replace returns a copy of root in which every value the path selects is
+// replaced by value. Containers that are not changed are shared with root.
+func (p *jsonPath) replace(root any, value any) any {
+	return replaceMatches(root, p.steps, value)
+}
+
+func replaceMatches(v any, steps []pathStep, value any) any {
+	if len(steps) == 0 {
+		return value
+	}
+	s := steps[0]
+	if s.recursive {
+		// Apply the step to v itself, then to all its descendants.
+		local := s
+		local.recursive = false
+		v = replaceMatches(v, append([]pathStep{local}, steps[1:]...), value)
+		switch c := v.(type) {
+		case map[string]any:
+			out := make(map[string]any, len(c))
+			for k, child := range c {
+				out[k] = replaceMatches(child, steps, value)
+			}
+			return out
+		case []any:
+			out := make([]any, len(c))
+			for i, child := range c {
+				out[i] = replaceMatches(child, steps, value)
+			}
+			return out
+		}
+		return v
+	}
+
+	switch c := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(c))
+		for k, child := range c {
+			out[k] = child
+			if s.selectsKey(k, child) {
+				out[k] = replaceMatches(child, steps[1:], value)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(c))
+		for i, child := range c {
+			out[i] = child
+			if s.selectsIndex(i, len(c), child) {
+				out[i] = replaceMatches(child, steps[1:], value)
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// selectsKey reports whether the step selects the object member k.
+func (s pathStep) selectsKey(k string, child any) bool {
+	switch s.kind {
+	case stepKey:
+		return s.key == k
+	case stepWildcard:
+		return true
+	case stepFilter:
+		return s.filter.match(child)
+	}
+	return false
+}
+
+// selectsIndex reports whether the step selects item i of an array of n
+// items.
+func (s pathStep) selectsIndex(i, n int, child any) bool {
+	switch s.kind {
+	case stepKey:
+		return s.key == strconv.Itoa(i)
+	case stepIndex:
+		return s.index == i || s.index < 0 && s.index+n == i
+	case stepWildcard:
+		return true
+	case stepSlice:
+		start, end := 0, n
+		if s.start != nil {
+			start = clampIndex(*s.start, n)
+		}
+		if s.end != nil {
+			end = clampIndex(*s.end, n)
+		}
+		return i >= start && i < end
+	case stepFilter:
+		return s.filter.match(child)
+	}
+	return false
+}
+
+// formatValue returns the script representation of a JSON value: strings
+// as-is, objects and arrays as JSON.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case float64:
+		// Not %v, which writes large numbers such as 1e+06 in exponent form.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// jsonType returns the JSON type name of v.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
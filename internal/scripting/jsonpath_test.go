@@ -0,0 +1,95 @@
+package scripting
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{
+		"store": {
+			"book": [
+				{"title": "A", "price": 8, "tags": ["x"]},
+				{"title": "B", "price": 12, "isbn": "1"},
+				{"title": "C", "price": 20, "isbn": "2"}
+			],
+			"a.b": "dotted",
+			"codes": [{"n": "a&&b"}, {"n": "x==y"}, {"n": "c||d"}]
+		}
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"store.book.0.title", []any{"A"}},
+		{"$.store.book[-1].title", []any{"C"}},
+		{"$.store.book[*].title", []any{"A", "B", "C"}},
+		{"$.store.book[1:].title", []any{"B", "C"}},
+		{"$.store.book[:1].price", []any{8.0}},
+		{"$..title", []any{"A", "B", "C"}},
+		{"$..book[?(@.price > 10)].title", []any{"B", "C"}},
+		{"$.store.book[?(@.isbn)].title", []any{"B", "C"}},
+		{"$.store.book[?(@.title == 'A' || @.price >= 20)].title", []any{"A", "C"}},
+		{"$.store.book[?(@.price < 15 && @.isbn)].title", []any{"B"}},
+		// Operators inside quoted values are part of the value.
+		{"$.store.codes[?(@.n == 'a&&b')].n", []any{"a&&b"}},
+		{`$.store.codes[?(@.n == 'x==y' || @.n == "c||d")].n`, []any{"x==y", "c||d"}},
+		{"$.store.codes[?(@.n != 'a&&b' && @.n contains '==')].n", []any{"x==y"}},
+		{"$.store['a.b']", []any{"dotted"}},
+		{`$.store["a.b"]`, []any{"dotted"}},
+		{"$.store[a.b]", []any{"dotted"}},
+		{"$.store.missing", []any(nil)},
+	}
+	for _, tt := range tests {
+		p, err := compilePath(tt.path)
+		if err != nil {
+			t.Errorf("compilePath(%q): %v", tt.path, err)
+			continue
+		}
+		if got := p.query(doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("query(%q) = %v; want %v", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"$.a[", "$.a[?(x)]", "$.a..", "$x"} {
+		if _, err := compilePath(path); err == nil {
+			t.Errorf("compilePath(%q): want error", path)
+		}
+	}
+}
+
+func TestJSONPathGet(t *testing.T) {
+	doc := map[string]any{"a": map[string]any{"list": []any{1.0}}, "s": "text"}
+	tests := []struct {
+		path    string
+		want    any
+		wantErr string
+	}{
+		{"$.a.list[0]", 1.0, ""},
+		{"$.a.b", nil, `key "b" not found at $.a`},
+		{"$.a.list[3]", nil, `array index 3 out of range at $.a.list`},
+		{"a.list.x", nil, `invalid array index "x" at $.a.list`},
+		{"$.s.x", nil, `cannot navigate into string value at $.s`},
+	}
+	for _, tt := range tests {
+		p, err := compilePath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.get(doc)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("get(%q) error = %v; want %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("get(%q) = %v, %v; want %v", tt.path, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package scripting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// arity is the number of arguments a command accepts. max is -1 for no limit.
+type arity struct{ min, max int }
+
+// commandArity lists the arguments of every command in commandNames. A
+// heredoc body counts as the last argument.
+var commandArity = map[string]arity{
+	"call_tool":            {1, -1},
+	"set_var":              {2, 2},
+	"input_var":            {1, -1},
+	"assert_contains":      {1, -1},
+	"assert_equals":        {1, -1},
+	"assert_number":        {1, 1},
+	"assert_gt":            {2, 2},
+	"assert_string_length": {3, -1},
+	"assert_error_code":    {1, 1},
+	"assert_path":          {3, 3},
+	"assert_schema":        {0, 1},
+	"assert_snapshot":      {1, -1},
+	"assert_matches":       {1, 2},
+	"assert_lt":            {1, 2},
+	"assert_lte":           {1, 2},
+	"assert_gte":           {1, 2},
+	"assert_between":       {2, 3},
+	"assert_type":          {1, 2},
+	"assert_len":           {1, 2},
+	"assert_empty":         {0, 1},
+	"not":                  {1, -1},
+	"assert_is_error":      {0, 0},
+	"assert_not_error":     {0, 0},
+	"assert_content_count": {1, 1},
+	"assert_content_type":  {2, 2},
+	"save_content":         {2, 2},
+	"timeout":              {2, -1},
+	"expect_error":         {1, -1},
+	"ping":                 {0, 0},
+	"logging":              {1, 1},
+	"rpc":                  {1, 2},
+	"read_resource":        {1, 1},
+	"validate_input":       {1, 1},
+	"validate_output":      {1, 1},
+	"dump_vars":            {0, 0},
+}
+
+func (a arity) String() string {
+	switch {
+	case a.min == a.max:
+		return strconv.Itoa(a.min)
+	case a.max < 0:
+		return fmt.Sprintf("at least %d", a.min)
+	default:
+		return fmt.Sprintf("%d to %d", a.min, a.max)
+	}
+}
+
+// Diagnostic is a problem found by Lint. Line is 0 for problems that are not
+// tied to a line.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// Lint checks the script at path without running it: syntax errors such as
+// unterminated heredocs, unknown commands, wrong argument counts and
+// variables that are used before they are set. If tools is not nil, call_tool
+// names and "key:" arguments are checked against the tools' input schemas.
+// The returned error is only set if the script cannot be read.
+func Lint(path string, tools []*mcp.Tool) ([]Diagnostic, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve script path: %w", err)
+	}
+	return lintScript(path, string(script), source{dir: filepath.Dir(path), chain: []string{abs}}, tools), nil
+}
+
+func lintScript(name, script string, src source, tools []*mcp.Tool) []Diagnostic {
+	r := &Runner{variables: make(map[string]any)}
+	nodes, err := r.parse(strings.Split(script, "\n"), 0, src)
+	if err != nil {
+		return []Diagnostic{{File: name, Message: err.Error()}}
+	}
+	r.define(nodes)
+
+	l := &linter{runner: r, name: name, tools: tools, defined: make(map[string]bool)}
+	setup, body, teardown := splitFixtures(nodes)
+	l.walk(setup)
+	l.walk(body)
+	l.walk(teardown)
+
+	// Functions can use any variable that is set somewhere in the script,
+	// since they may be called after it was set.
+	for _, n := range nodes {
+		if def, ok := n.(*defNode); ok {
+			saved := l.defined
+			l.defined = make(map[string]bool, len(saved)+len(def.params))
+			for k := range saved {
+				l.defined[k] = true
+			}
+			for _, p := range def.params {
+				l.defined[p] = true
+			}
+			l.walk(def.body)
+			l.defined = saved
+		}
+	}
+	return l.diags
+}
+
+// linter walks a parse tree in execution order and collects diagnostics.
+type linter struct {
+	runner  *Runner
+	name    string
+	tools   []*mcp.Tool
+	defined map[string]bool
+	diags   []Diagnostic
+}
+
+func (l *linter) report(at pos, format string, args ...any) {
+	file := at.file
+	if file == "" {
+		file = l.name
+	}
+	l.diags = append(l.diags, Diagnostic{File: file, Line: at.idx + 1, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) walk(nodes []node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *commandNode:
+			l.checkVars(n.pos, n.text)
+			parts, _ := l.runner.parseArgs(n.text)
+			if n.heredoc != nil {
+				parts = append(parts, *n.heredoc)
+			}
+			l.checkCommand(n.pos, parts)
+		case *ifNode:
+			l.checkVars(n.pos, n.cond)
+			l.walk(n.then)
+			l.walk(n.els)
+		case *forNode:
+			l.checkVars(n.pos, n.list)
+			// The loop variable is restored after the loop.
+			wasDefined := l.defined[n.name]
+			l.defined[n.name] = true
+			l.walk(n.body)
+			if !wasDefined {
+				delete(l.defined, n.name)
+			}
+		case *letNode:
+			l.checkNames(n.pos, exprVars(n.expr))
+			l.defined[n.name] = true
+		case *repeatNode:
+			l.checkVars(n.pos, n.count)
+			l.walk(n.body)
+		case *fixtureNode:
+			l.walk(n.body)
+		case *testNode:
+			l.walk(n.body)
+		}
+	}
+}
+
+// checkVars reports references to variables that are not set at this point
+// and invalid ${...} expressions.
+func (l *linter) checkVars(at pos, text string) {
+	names, err := varRefs(text)
+	if err != nil {
+		l.report(at, "%v", err)
+	}
+	l.checkNames(at, names)
+}
+
+func (l *linter) checkNames(at pos, names []string) {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !l.defined[name] && !seen[name] {
+			seen[name] = true
+			l.report(at, "undefined variable $%s", name)
+		}
+	}
+}
+
+func (l *linter) checkCommand(at pos, parts []string) {
+	if len(parts) == 0 {
+		return
+	}
+	name, args := parts[0], parts[1:]
+	if fn, ok := l.runner.funcs[name]; ok {
+		if len(args) != len(fn.params) {
+			l.report(at, "%s expects %d arguments, got %d", name, len(fn.params), len(args))
+		}
+		return
+	}
+	a, ok := commandArity[name]
+	if !ok {
+		if s := suggest(name, l.runner.funcs); s != "" {
+			l.report(at, "unknown command %s (did you mean %s?)", name, s)
+		} else {
+			l.report(at, "unknown command %s", name)
+		}
+		return
+	}
+	if len(args) < a.min || a.max >= 0 && len(args) > a.max {
+		l.report(at, "%s expects %s arguments, got %d", name, a, len(args))
+		return
+	}
+
+	switch name {
+	case "set_var", "input_var":
+		l.defined[args[0]] = true
+	case "timeout":
+		if _, err := strconv.Atoi(args[0]); err != nil && !strings.HasPrefix(args[0], "$") {
+			l.report(at, "invalid timeout value: %s", args[0])
+		}
+		l.checkCommand(at, args[1:])
+	case "expect_error":
+		l.checkCommand(at, args)
+	case "not":
+		if !strings.HasPrefix(args[0], "assert_") {
+			l.report(at, "not expects an assert_ command, got %s", args[0])
+			return
+		}
+		l.checkCommand(at, args)
+	case "call_tool":
+		if l.tools != nil {
+			l.checkToolCall(at, args[0], args[1:])
+		}
+	}
+}
+
+// checkToolCall checks the tool name and the "key:" arguments of a call_tool
+// command against the tools of the server.
+func (l *linter) checkToolCall(at pos, name string, args []string) {
+	if strings.Contains(name, "$") {
+		return
+	}
+	i := slices.IndexFunc(l.tools, func(t *mcp.Tool) bool { return t.Name == name })
+	if i < 0 {
+		l.report(at, "unknown tool %s", name)
+		return
+	}
+	if _, ok := l.tools[i].InputSchema.(map[string]any); !ok {
+		return
+	}
+	keys := toolArgumentKeys(l.tools, name)
+	for _, arg := range args {
+		key, val, ok := strings.Cut(arg, ":")
+		// Values like URLs contain a colon but are positional arguments.
+		if !ok || !isIdentifier(key) || strings.HasPrefix(val, "//") {
+			continue
+		}
+		if !slices.Contains(keys, key) {
+			l.report(at, "tool %s has no argument %s", name, key)
+		}
+	}
+}
+
+// suggest returns the command or function name closest to name, if it is
+// likely a typo of it.
+func suggest(name string, funcs map[string]*defNode) string {
+	candidates := slices.Clone(commandNames)
+	for fn := range funcs {
+		candidates = append(candidates, fn)
+	}
+	slices.Sort(candidates)
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,107 @@
+package scripting
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCommandArity(t *testing.T) {
+	for _, name := range commandNames {
+		if _, ok := commandArity[name]; !ok {
+			t.Errorf("no arity for command %s", name)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	tools := []*mcp.Tool{{
+		Name: "echo",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"message": map[string]any{"type": "string"}},
+		},
+	}}
+	script := `asert_equals a b
+set_var id result.id
+call_tool echo message:$id
+call_tool echo mesage:hi
+call_tool ech hi
+call_tool echo http://example.com
+assert_gt 1
+ping now
+timeout 1s ping
+expect_error read_resource
+assert_equals $missing x
+for $x in 1..2
+  check $x
+end
+assert_equals $x 1
+def check(v)
+  assert_equals $v $later
+end
+input_var later
+check 1 2
+rpc tools/list <<JSON
+{}
+JSON
+let n = len(id) + unset
+assert_equals ${n * 2} ${n +}`
+	got := lintScript("t.mcp", script, source{dir: "."}, tools)
+	want := []string{
+		"t.mcp:1: unknown command asert_equals (did you mean assert_equals?)",
+		"t.mcp:4: tool echo has no argument mesage",
+		"t.mcp:5: unknown tool ech",
+		"t.mcp:7: assert_gt expects 2 arguments, got 1",
+		"t.mcp:8: ping expects 0 arguments, got 1",
+		"t.mcp:9: invalid timeout value: 1s",
+		"t.mcp:10: read_resource expects 1 arguments, got 0",
+		"t.mcp:11: undefined variable $missing",
+		"t.mcp:15: undefined variable $x",
+		"t.mcp:20: check expects 1 arguments, got 2",
+		"t.mcp:24: undefined variable $unset",
+		`t.mcp:25: invalid expression "n +": unexpected end`,
+	}
+	var lines []string
+	for _, d := range got {
+		lines = append(lines, d.String())
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Lint =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Without tools, tool names and arguments are not checked.
+	if got := lintScript("t.mcp", "call_tool ech mesage:hi", source{dir: "."}, nil); len(got) != 0 {
+		t.Errorf("Lint without tools = %v; want no diagnostics", got)
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"main.mcp":   "include \"lib.mcp\"\nset_var a x\n",
+		"lib.mcp":    "assert_equals $a 1\n",
+		"broken.mcp": "rpc tools/list <<JSON\n{}\n",
+	})
+	got, err := Lint(filepath.Join(dir, "main.mcp"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].File != filepath.Join(dir, "lib.mcp") || got[0].Line != 1 || got[0].Message != "undefined variable $a" {
+		t.Errorf("Lint(main.mcp) = %v", got)
+	}
+
+	got, err = Lint(filepath.Join(dir, "broken.mcp"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0].Message, "heredoc marker not found") {
+		t.Errorf("Lint(broken.mcp) = %v; want unterminated heredoc", got)
+	}
+
+	if _, err := Lint(filepath.Join(dir, "missing.mcp"), nil); err == nil {
+		t.Error("Lint(missing.mcp): want error")
+	}
+}
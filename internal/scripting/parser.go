@@ -1,6 +1,135 @@
 package scripting
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Errors for scripts that end before a heredoc or block is closed. Interactive
+// input uses them to decide whether more lines are needed.
+var (
+	errUnclosedHeredoc = errors.New("heredoc marker not found")
+	errUnclosedBlock   = errors.New("missing end")
+)
+
+// blockKeywords are the statements that are not commands: the words that
+// open, continue or close a block, include and let.
+var blockKeywords = []string{"if", "else", "for", "repeat", "def", "setup", "teardown", "include", "test", "end", "let"}
+
+// node is a statement of a parsed script.
+type node interface {
+	position() pos
+}
+
+// pos is the location of a statement: the zero-based index of the source line
+// it starts on and, for statements from an included file, the file name. code
+// is the source line without comments.
+type pos struct {
+	file string
+	idx  int
+	code string
+}
+
+func (p pos) position() pos { return p }
+
+func (p pos) line() int { return p.idx }
+
+func (p pos) String() string {
+	if p.file == "" {
+		return fmt.Sprintf("line %d", p.idx+1)
+	}
+	return fmt.Sprintf("line %d of %s", p.idx+1, p.file)
+}
+
+// wrap prefixes errors of statements from included files with the file name.
+// The error itself already carries the line number.
+func (p pos) wrap(err error) error {
+	if err == nil || p.file == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", p.file, err)
+}
+
+// commandNode is a single command. A heredoc body is passed as last argument.
+type commandNode struct {
+	pos
+	text    string
+	heredoc *string
+}
+
+// ifNode is "if <cond> ... [else ...] end". "else if" is an ifNode as the
+// only statement of els.
+type ifNode struct {
+	pos
+	cond string
+	then []node
+	els  []node
+}
+
+// forNode is "for $name in <list> ... end".
+type forNode struct {
+	pos
+	name string
+	list string
+	body []node
+}
+
+// letNode is "let <name> = <expr>".
+type letNode struct {
+	pos
+	name string
+	expr exprNode
+}
+
+// repeatNode is "repeat <count> ... end".
+type repeatNode struct {
+	pos
+	count string
+	body  []node
+}
+
+// defNode is "def name(params) ... end".
+type defNode struct {
+	pos
+	name   string
+	params []string
+	body   []node
+}
+
+// fixtureNode is a "setup ... end" or "teardown ... end" section.
+type fixtureNode struct {
+	pos
+	teardown bool
+	body     []node
+}
+
+// testNode is a named test case: "test "name" ... end".
+type testNode struct {
+	pos
+	name string
+	body []node
+}
+
+// source describes the script being parsed. Includes are resolved relative
+// to dir.
+type source struct {
+	name  string   // file name for error messages, "" for the main script
+	dir   string   // directory of the script
+	chain []string // absolute paths of the including files, for cycle detection
+}
+
+// includeError is an error in an included file.
+type includeError struct {
+	name string
+	err  error
+}
+
+func (e *includeError) Error() string { return e.name + ": " + e.err.Error() }
+func (e *includeError) Unwrap() error { return e.err }
 
 // preprocessLine trims whitespace and removes comments from a script line.
 func (r *Runner) preprocessLine(line string) string {
@@ -19,3 +148,283 @@ func (r *Runner) preprocessLine(line string) string {
 
 	return line
 }
+
+// openBlock is a block whose end has not been reached yet.
+type openBlock struct {
+	keyword string
+	node    node
+	body    *[]node
+	inElse  bool
+	chained bool // opened by "else if" and closed by the end of its parent
+}
+
+// parse builds the parse tree of a script. offset is the index of the first
+// line, so statements entered interactively keep counting lines. Included
+// files are parsed recursively and their statements inserted in place.
+func (r *Runner) parse(lines []string, offset int, src source) ([]node, error) {
+	var root []node
+	var stack []*openBlock
+	add := func(n node) {
+		if len(stack) == 0 {
+			root = append(root, n)
+			return
+		}
+		top := stack[len(stack)-1]
+		*top.body = append(*top.body, n)
+	}
+
+	for i := 0; i < len(lines); i++ {
+		idx := offset + i
+		line := r.preprocessLine(lines[i])
+		at := pos{file: src.name, idx: idx, code: line}
+		if line == "" {
+			continue
+		}
+		keyword, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		switch keyword {
+		case "if":
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: if requires a condition", idx+1)
+			}
+			n := &ifNode{pos: at, cond: rest}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.then})
+
+		case "else":
+			var top *openBlock
+			if len(stack) > 0 {
+				top = stack[len(stack)-1]
+			}
+			if top == nil || top.keyword != "if" || top.inElse {
+				return nil, fmt.Errorf("line %d: else without if", idx+1)
+			}
+			n := top.node.(*ifNode)
+			top.inElse = true
+			top.body = &n.els
+			if rest == "" {
+				continue
+			}
+			cond, ok := strings.CutPrefix(rest, "if ")
+			if !ok || strings.TrimSpace(cond) == "" {
+				return nil, fmt.Errorf("line %d: expected \"else\" or \"else if <condition>\"", idx+1)
+			}
+			nested := &ifNode{pos: at, cond: strings.TrimSpace(cond)}
+			add(nested)
+			stack = append(stack, &openBlock{keyword: "if", node: nested, body: &nested.then, chained: true})
+
+		case "for":
+			fields := strings.Fields(rest)
+			if len(fields) < 3 || fields[1] != "in" {
+				return nil, fmt.Errorf("line %d: usage: for $var in <list>", idx+1)
+			}
+			name := strings.TrimPrefix(fields[0], "$")
+			_, list, _ := strings.Cut(rest, " in ")
+			n := &forNode{pos: at, name: name, list: strings.TrimSpace(list)}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.body})
+
+		case "repeat":
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: usage: repeat <count>", idx+1)
+			}
+			n := &repeatNode{pos: at, count: rest}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.body})
+
+		case "def":
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("line %d: def is only allowed at the top level", idx+1)
+			}
+			n, err := parseDef(at, rest)
+			if err != nil {
+				return nil, err
+			}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.body})
+
+		case "setup", "teardown":
+			if rest != "" {
+				return nil, fmt.Errorf("line %d: unexpected text after %s: %s", idx+1, keyword, rest)
+			}
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("line %d: %s is only allowed at the top level", idx+1, keyword)
+			}
+			n := &fixtureNode{pos: at, teardown: keyword == "teardown"}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.body})
+
+		case "test":
+			args, _ := r.parseArgs(rest)
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: usage: test \"name\"", idx+1)
+			}
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("line %d: test is only allowed at the top level", idx+1)
+			}
+			n := &testNode{pos: at, name: args[0]}
+			add(n)
+			stack = append(stack, &openBlock{keyword: keyword, node: n, body: &n.body})
+
+		case "let":
+			n, err := parseLet(at, rest)
+			if err != nil {
+				return nil, err
+			}
+			add(n)
+
+		case "include":
+			nodes, err := r.parseInclude(at, rest, src)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range nodes {
+				switch n.(type) {
+				case *defNode, *fixtureNode, *testNode:
+					if len(stack) > 0 {
+						return nil, fmt.Errorf("line %d: included file %s contains top-level sections and must be included at the top level", idx+1, rest)
+					}
+				}
+				add(n)
+			}
+
+		case "end":
+			if rest != "" {
+				return nil, fmt.Errorf("line %d: unexpected text after end: %s", idx+1, rest)
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: end without block", idx+1)
+			}
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if !top.chained {
+					break
+				}
+			}
+
+		default:
+			n := &commandNode{pos: at, text: line}
+			if pos := strings.Index(line, "<<"); pos != -1 {
+				marker := strings.TrimSpace(line[pos+2:])
+				n.text = strings.TrimSpace(line[:pos])
+				var body []string
+				closed := false
+				for i+1 < len(lines) {
+					i++
+					if strings.TrimSpace(lines[i]) == marker {
+						closed = true
+						break
+					}
+					body = append(body, lines[i])
+				}
+				if !closed {
+					return nil, fmt.Errorf("line %d: %w: %s", idx+1, errUnclosedHeredoc, marker)
+				}
+				content := strings.Join(body, "\n")
+				n.heredoc = &content
+			}
+			add(n)
+		}
+	}
+
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		return nil, fmt.Errorf("line %d: %s block: %w", top.node.position().idx+1, top.keyword, errUnclosedBlock)
+	}
+	return root, nil
+}
+
+// parseInclude parses the file named by an include statement. The path is
+// resolved relative to the including script.
+func (r *Runner) parseInclude(at pos, arg string, src source) ([]node, error) {
+	args, _ := r.parseArgs(arg)
+	if len(args) != 1 {
+		return nil, fmt.Errorf("line %d: usage: include \"path\"", at.idx+1)
+	}
+	path := args[0]
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(src.dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: include %s: %w", at.idx+1, args[0], err)
+	}
+	if slices.Contains(src.chain, abs) {
+		return nil, fmt.Errorf("line %d: include cycle: %s is already being included", at.idx+1, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: include: %w", at.idx+1, err)
+	}
+
+	nodes, err := r.parse(strings.Split(string(data), "\n"), 0, source{
+		name:  path,
+		dir:   filepath.Dir(path),
+		chain: append(slices.Clone(src.chain), abs),
+	})
+	if err != nil {
+		var ie *includeError
+		if errors.As(err, &ie) {
+			return nil, err
+		}
+		return nil, &includeError{name: path, err: err}
+	}
+	return nodes, nil
+}
+
+// parseDef parses the "name(a, b)" part of a def line.
+func parseDef(at pos, header string) (*defNode, error) {
+	name, params, ok := strings.Cut(header, "(")
+	params, closed := strings.CutSuffix(strings.TrimSpace(params), ")")
+	name = strings.TrimSpace(name)
+	if !ok || !closed || !isIdentifier(name) {
+		return nil, fmt.Errorf("line %d: usage: def name(arg1, arg2, ...)", at.idx+1)
+	}
+	if slices.Contains(commandNames, name) || slices.Contains(blockKeywords, name) {
+		return nil, fmt.Errorf("line %d: cannot redefine built-in command %s", at.idx+1, name)
+	}
+
+	n := &defNode{pos: at, name: name}
+	if strings.TrimSpace(params) == "" {
+		return n, nil
+	}
+	for _, p := range strings.Split(params, ",") {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "$")
+		if !isIdentifier(p) || slices.Contains(n.params, p) {
+			return nil, fmt.Errorf("line %d: invalid parameter %q in def %s", at.idx+1, p, name)
+		}
+		n.params = append(n.params, p)
+	}
+	return n, nil
+}
+
+// parseLet parses the "name = expr" part of a let line.
+func parseLet(at pos, rest string) (*letNode, error) {
+	name, src, ok := strings.Cut(rest, "=")
+	name = strings.TrimPrefix(strings.TrimSpace(name), "$")
+	if !ok || !isIdentifier(name) || strings.HasPrefix(src, "=") {
+		return nil, fmt.Errorf("line %d: usage: let name = <expression>", at.idx+1)
+	}
+	x, err := parseExpr(strings.TrimSpace(src))
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", at.idx+1, err)
+	}
+	return &letNode{pos: at, name: name, expr: x}, nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package scripting
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -31,3 +33,79 @@ func TestPreprocessLine(t *testing.T) {
 		})
 	}
 }
+
+func TestParse(t *testing.T) {
+	r := &Runner{}
+	script := `# blocks
+def greet(name)
+  assert_equals $name World
+end
+for $x in 1..3
+  if $x == 1
+    ping
+  else if $x == 2
+    call_tool echo <<EOF
+hello
+EOF
+  else
+    repeat 2
+      ping
+    end
+  end
+end`
+	nodes, err := r.parse(strings.Split(script, "\n"), 0, source{})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("got %d top-level nodes; want 2", len(nodes))
+	}
+	def, ok := nodes[0].(*defNode)
+	if !ok || def.name != "greet" || !reflect.DeepEqual(def.params, []string{"name"}) || def.line() != 1 {
+		t.Errorf("def = %+v", nodes[0])
+	}
+	loop, ok := nodes[1].(*forNode)
+	if !ok || loop.name != "x" || loop.list != "1..3" || len(loop.body) != 1 {
+		t.Fatalf("for = %+v", nodes[1])
+	}
+	cond := loop.body[0].(*ifNode)
+	if cond.cond != "$x == 1" || cond.line() != 5 || len(cond.then) != 1 || len(cond.els) != 1 {
+		t.Fatalf("if = %+v", cond)
+	}
+	elseIf := cond.els[0].(*ifNode)
+	call := elseIf.then[0].(*commandNode)
+	if call.text != "call_tool echo" || call.heredoc == nil || *call.heredoc != "hello" || call.line() != 8 {
+		t.Errorf("heredoc command = %+v", call)
+	}
+	repeat := elseIf.els[0].(*repeatNode)
+	if repeat.count != "2" || repeat.body[0].position().line() != 13 {
+		t.Errorf("repeat = %+v", repeat)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	r := &Runner{}
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"ping\nend", "line 2: end without block"},
+		{"ping\nelse", "line 2: else without if"},
+		{"if 1\nelse\nelse\nend", "line 3: else without if"},
+		{"for $x in 1..2\n  ping", "line 1: for block: missing end"},
+		{"ping\nif 1\n  repeat 2\n    ping", "line 3: repeat block: missing end"},
+		{"ping\ncall_tool echo <<EOF\nhi", "line 2: heredoc marker not found: EOF"},
+		{"for x 1..2\nend", "line 1: usage: for $var in <list>"},
+		{"if 1\n  def f()\n  end\nend", "line 2: def is only allowed at the top level"},
+		{"def ping()\nend", "line 1: cannot redefine built-in command ping"},
+		{"def f(a, a)\nend", `line 1: invalid parameter "a" in def f`},
+		{"let x == 1", "line 1: usage: let name = <expression>"},
+		{"let x = 1 +", `line 1: invalid expression "1 +": unexpected end`},
+	}
+	for _, tt := range tests {
+		_, err := r.parse(strings.Split(tt.script, "\n"), 0, source{})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parse(%q) error = %v; want %q", tt.script, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits for generated assertions: responses up to maxEqualsLength are matched
+// exactly, longer ones by a fragment of at most maxFragmentLength runes.
+const (
+	maxEqualsLength   = 120
+	minFragmentLength = 3
+	maxFragmentLength = 40
+)
+
+// Recorder executes script lines interactively and writes every successful
+// command, followed by assertions generated from the observed response, to a
+// script. The lines run through the same Runner as a script file, so the
+// recorded script replays exactly what was typed.
+type Recorder struct {
+	*Interactive
+	runner  *Runner
+	out     io.Writer
+	pending []string
+}
+
+// NewRecorder creates a Recorder that writes the script to out.
+func NewRecorder(runner *Runner, out io.Writer) *Recorder {
+	return &Recorder{Interactive: NewInteractive(runner), runner: runner, out: out}
+}
+
+// Record executes one input line. Comments are copied to the script. A failed
+// command is reported and not written, so it can simply be retyped.
+func (rec *Recorder) Record(ctx context.Context, line string) error {
+	if !rec.Pending() {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			return nil
+		}
+		if rec.runner.preprocessLine(line) == "" {
+			return rec.write(trimmed)
+		}
+	}
+
+	rec.pending = append(rec.pending, line)
+	executed, err := rec.Exec(ctx, line)
+	if rec.Pending() {
+		return nil
+	}
+	lines := rec.pending
+	rec.pending = nil
+	if err != nil || !executed {
+		return err
+	}
+
+	for _, l := range lines {
+		if err := rec.write(l); err != nil {
+			return err
+		}
+	}
+	for _, a := range rec.assertions(lines[0]) {
+		if err := rec.write(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rec *Recorder) write(line string) error {
+	_, err := fmt.Fprintln(rec.out, line)
+	return err
+}
+
+// assertions generates checks for the response of the recorded command.
+func (rec *Recorder) assertions(line string) []string {
+	parts, _ := rec.runner.parseArgs(rec.runner.preprocessLine(line))
+	if len(parts) > 2 && parts[0] == "timeout" {
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+
+	switch parts[0] {
+	case "expect_error":
+		return []string{fmt.Sprintf("assert_error_code %d", rec.runner.lastErrorCode)}
+	case "call_tool", "rpc", "read_resource":
+		text := rec.runner.lastText
+		if lit, ok := scriptLiteral(text); ok && utf8.RuneCountInString(text) <= maxEqualsLength {
+			return []string{"assert_equals " + lit}
+		}
+		if lit, ok := scriptLiteral(fragment(text)); ok {
+			return []string{"assert_contains " + lit}
+		}
+	}
+	return nil
+}
+
+// scriptLiteral quotes s as a single script argument. It reports false if s
+// cannot be written literally, because it is empty, spans lines, or contains
+// sequences the script preprocessor would interpret.
+func scriptLiteral(s string) (string, bool) {
+	if strings.TrimSpace(s) == "" || strings.ContainsAny(s, "\r\n$") || hasScriptSyntax(s) {
+		return "", false
+	}
+	switch {
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`, true
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'", true
+	}
+	return "", false
+}
+
+func hasScriptSyntax(s string) bool {
+	return strings.Contains(s, " #") || strings.Contains(s, " //") || strings.Contains(s, "<<")
+}
+
+// fragment returns a stable prefix of the first line of text that can be
+// quoted, or "" if it would be too short to be meaningful.
+func fragment(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if i := strings.IndexAny(line, "\r$\"'"); i != -1 {
+		line = line[:i]
+	}
+	for _, seq := range []string{" #", " //", "<<"} {
+		if i := strings.Index(line, seq); i != -1 {
+			line = line[:i]
+		}
+	}
+	if utf8.RuneCountInString(line) > maxFragmentLength {
+		line = string([]rune(line)[:maxFragmentLength])
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(line)) < minFragmentLength {
+		return ""
+	}
+	return line
+}
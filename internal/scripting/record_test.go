@@ -0,0 +1,72 @@
+package scripting
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	r := &Runner{
+		variables:  make(map[string]any),
+		lastText:   "Hello World",
+		lastRawMap: map[string]any{"id": "42"},
+	}
+	var out strings.Builder
+	rec := NewRecorder(r, &out)
+	ctx := context.Background()
+
+	for _, line := range []string{
+		"# check greeting",
+		"",
+		"assert_contains World",
+		"assert_contains Universe",
+		"assert_equals <<END",
+		"Hello World",
+		"END",
+		"set_var id id",
+	} {
+		err := rec.Record(ctx, line)
+		if (err != nil) != (line == "assert_contains Universe") {
+			t.Errorf("Record(%q) error = %v", line, err)
+		}
+	}
+	if rec.Pending() {
+		t.Error("input should be complete")
+	}
+
+	want := "# check greeting\nassert_contains World\nassert_equals <<END\nHello World\nEND\nset_var id id\n"
+	if out.String() != want {
+		t.Errorf("recorded script = %q; want %q", out.String(), want)
+	}
+}
+
+func TestRecorderAssertions(t *testing.T) {
+	r := &Runner{lastErrorCode: -32602}
+	rec := NewRecorder(r, &strings.Builder{})
+
+	tests := []struct {
+		line     string
+		lastText string
+		want     string
+	}{
+		{"call_tool echo hi", "Echo: hi", `assert_equals "Echo: hi"`},
+		{"timeout 500 call_tool echo hi", "Echo: hi", `assert_equals "Echo: hi"`},
+		{"call_tool quote", `say "hi"`, `assert_equals 'say "hi"'`},
+		{"call_tool multi", "first line\nsecond line", `assert_contains "first line"`},
+		{"call_tool long", strings.Repeat("abcdefghij", 20), `assert_contains "` + strings.Repeat("abcdefghij", 4) + `"`},
+		{"call_tool price", "costs $5", `assert_contains "costs "`},
+		{"call_tool comment", "a # b", ""},
+		{"call_tool empty", "", ""},
+		{"read_resource mcp://time", "12:00", `assert_equals "12:00"`},
+		{"expect_error call_tool bad", "ignored", "assert_error_code -32602"},
+		{"ping", "Echo: hi", ""},
+	}
+	for _, tt := range tests {
+		r.lastText = tt.lastText
+		got := strings.Join(rec.assertions(tt.line), "\n")
+		if got != tt.want {
+			t.Errorf("assertions(%q) with text %q = %q; want %q", tt.line, tt.lastText, got, tt.want)
+		}
+	}
+}
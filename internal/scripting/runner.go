@@ -5,140 +5,251 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/hmsoft0815/mlc_mcptester/internal/client"
 	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // Runner manages the execution of MCP test scripts.
 type Runner struct {
-	session       *mcp.ClientSession
-	lastResponse  string // The full JSON response
-	lastText      string // Just the text content
-	lastRawMap    map[string]any
-	Raw           bool
-	variables     map[string]string
-	lastErrorCode int64
+	session         *mcp.ClientSession
+	rpc             *client.Raw
+	lastResponse    string // The full JSON response
+	lastText        string // Just the text content
+	lastRawMap      map[string]any
+	lastTool        *mcp.Tool // tool of the last call_tool, nil if unknown
+	Raw             bool
+	Output          io.Writer              // where command output goes, os.Stdout if nil
+	MaxFailures     int                    // stop the script after this many failures, 0 for no limit
+	OnCommand       func(failed bool) bool // called after every command, the script stops if it returns false
+	ValidateInput   bool                   // check tool arguments against the tool's inputSchema before sending
+	ValidateOutput  bool                   // check tool results against the tool's outputSchema
+	UpdateSnapshots bool                   // replace snapshots that do not match instead of failing
+	variables       map[string]any         // script variables, as decoded JSON values
+	scopes          []*scope               // local variables of active loops and function calls
+	argValues       []argValue             // typed values of the arguments of the current command
+	lastErrorCode   int64
+	funcs           map[string]*defNode
+	dir             string // directory of the script, for files it refers to
+	script          string // file name of the script, "" if not run from a file
 }
 
-// TestResult holds numeric summary of test execution
+// TestResult holds numeric summary of test execution. Commands holds the
+// details of every executed command for reports. Error is set if the script
+// could not be run at all, Stopped if it ended early at Runner.MaxFailures or
+// because Runner.OnCommand returned false.
 type TestResult struct {
-	Executed int `json:"executed"`
-	Passed   int `json:"passed"`
-	Failed   int `json:"failed"`
+	Script   string           `json:"script,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Stopped  bool             `json:"stopped,omitempty"`
+	Executed int              `json:"executed"`
+	Passed   int              `json:"passed"`
+	Failed   int              `json:"failed"`
+	Tests    []TestCaseResult `json:"tests,omitempty"`
+	Commands []CommandResult  `json:"-"`
 }
 
-// NewRunner creates a new Runner with the given MCP client session.
-func NewRunner(session *mcp.ClientSession, raw bool) *Runner {
+// SyntaxError is returned by Run and RunFile for a script that cannot be
+// parsed. No command has been executed.
+type SyntaxError struct {
+	Err error
+}
+
+func (e *SyntaxError) Error() string { return e.Err.Error() }
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// OK reports whether the script ran and nothing failed.
+func (t *TestResult) OK() bool {
+	return t.Error == "" && t.Failed == 0
+}
+
+// Test case statuses.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// TestCaseResult is the outcome of a "test" block. Failure holds the first
+// error, which ended the test.
+type TestCaseResult struct {
+	Name       string  `json:"name"`
+	File       string  `json:"file,omitempty"`
+	Line       int     `json:"line"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Failure    string  `json:"failure,omitempty"`
+}
+
+// CommandResult is the outcome of a single command. File is set for commands
+// from included files, Test for commands inside a test block.
+type CommandResult struct {
+	File       string  `json:"file,omitempty"`
+	Line       int     `json:"line"`
+	Command    string  `json:"command"`
+	Test       string  `json:"test,omitempty"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Failure    string  `json:"failure,omitempty"`
+	Response   string  `json:"response,omitempty"`
+}
+
+// NewRunner creates a new Runner with the given MCP client session. rpc must be
+// the raw client whose transport the session was connected with.
+func NewRunner(session *mcp.ClientSession, rpc *client.Raw, raw bool) *Runner {
 	return &Runner{
-		session:   session,
-		Raw:       raw,
-		variables: make(map[string]string),
+		session:       session,
+		rpc:           rpc,
+		Raw:           raw,
+		ValidateInput: true,
+		variables:     make(map[string]any),
+	}
+}
+
+// Variables returns a copy of the visible variables.
+func (r *Runner) Variables() map[string]any {
+	vars := make(map[string]any, len(r.variables))
+	for k, v := range r.variables {
+		vars[k] = v
+	}
+	visible := r.visibleScopes()
+	for i := len(visible) - 1; i >= 0; i-- {
+		for k, v := range visible[i].vars {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// out returns the writer for command output.
+func (r *Runner) out() io.Writer {
+	if r.Output == nil {
+		return os.Stdout
 	}
+	return r.Output
 }
 
-type runState struct {
-	accumulating   bool
-	heredocMarker  string
-	heredocContent strings.Builder
-	currentCommand string
-	executed       int
-	passed         int
-	failed         int
+// LastResponse returns the JSON of the last response, or "" if there is none.
+func (r *Runner) LastResponse() string {
+	return r.lastResponse
 }
 
-// Run executes a script string against the established MCP session.
+// Run executes a script string against the established MCP session. A
+// script that cannot be parsed is rejected before any command runs. Includes
+// are resolved relative to the working directory.
 func (r *Runner) Run(ctx context.Context, script string, outputFormat string) (*TestResult, error) {
-	lines := strings.Split(script, "\n")
-	state := &runState{}
-
-	for i, line := range lines {
-		if err := r.processLine(ctx, i, line, state); err != nil {
-			state.failed++
-			if outputFormat == "text" {
-				fmt.Printf("Error: %v\n", err)
-			}
-		}
+	return r.run(ctx, "", script, source{dir: "."}, outputFormat)
+}
+
+// RunFile executes the script at path. Includes are resolved relative to it.
+func (r *Runner) RunFile(ctx context.Context, path string, outputFormat string) (*TestResult, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve script path: %w", err)
 	}
+	return r.run(ctx, path, string(script), source{dir: filepath.Dir(path), chain: []string{abs}}, outputFormat)
+}
 
-	if state.accumulating {
-		return nil, fmt.Errorf("error: heredoc marker %q not found", state.heredocMarker)
+// run executes the setup sections, then the script, then the teardown
+// sections. The script is skipped if a setup command fails; teardown always
+// runs, even if ctx is already cancelled.
+func (r *Runner) run(ctx context.Context, name, script string, src source, outputFormat string) (*TestResult, error) {
+	nodes, err := r.parse(strings.Split(script, "\n"), 0, src)
+	if err != nil {
+		return nil, &SyntaxError{Err: err}
 	}
+	r.define(nodes)
+	r.dir = src.dir
+	r.script = name
+	setup, body, teardown := splitFixtures(nodes)
+
+	state := &runState{maxFailures: r.MaxFailures, onCommand: r.OnCommand}
+	if outputFormat == "text" {
+		state.onError = func(err error) { fmt.Fprintf(r.out(), "Error: %v\n", err) }
+		state.onTest = r.printTestCase
+	}
+	r.exec(ctx, setup, state)
+	if state.failed == 0 {
+		r.exec(ctx, body, state)
+	} else {
+		if outputFormat == "text" {
+			fmt.Fprint(r.out(), i18n.T(i18n.MsgSetupFailed))
+		}
+		state.skipTests(body)
+	}
+	stopped := state.halted
+	if stopped && outputFormat == "text" {
+		if r.MaxFailures > 0 && state.failed >= r.MaxFailures {
+			fmt.Fprint(r.out(), i18n.T(i18n.MsgMaxFailures, state.failed))
+		} else {
+			fmt.Fprint(r.out(), i18n.T(i18n.MsgFailureLimit))
+		}
+	}
+	// Teardown runs completely, even after the failure limit was reached.
+	state.halted = false
+	state.maxFailures = 0
+	if onCommand := state.onCommand; onCommand != nil {
+		state.onCommand = func(failed bool) bool {
+			onCommand(failed)
+			return true
+		}
+	}
+	r.exec(context.WithoutCancel(ctx), teardown, state)
 
 	result := &TestResult{
+		Script:   name,
+		Stopped:  stopped,
 		Executed: state.executed,
 		Passed:   state.passed,
 		Failed:   state.failed,
+		Tests:    state.tests,
+		Commands: state.commands,
 	}
 
 	if outputFormat == "text" {
-		fmt.Print(i18n.T(i18n.MsgTestSummary, result.Executed, result.Passed, result.Failed))
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgTestSummary, result.Executed, result.Passed, result.Failed))
+		if len(result.Tests) > 0 {
+			counts := make(map[string]int)
+			for _, tc := range result.Tests {
+				counts[tc.Status]++
+			}
+			fmt.Fprint(r.out(), i18n.T(i18n.MsgTestCaseSummary, counts[StatusPassed], counts[StatusFailed], counts[StatusSkipped]))
+		}
 	} else if outputFormat == "json" {
 		out, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(out))
+		fmt.Fprintln(r.out(), string(out))
 	}
 
 	return result, nil
 }
 
-func (r *Runner) processLine(ctx context.Context, i int, line string, state *runState) error {
-	if state.accumulating {
-		if strings.TrimSpace(line) == state.heredocMarker {
-			state.accumulating = false
-			return r.finalizeHeredoc(ctx, i, state)
-		}
-		state.heredocContent.WriteString(line + "\n")
-		return nil
-	}
-
-	processedLine := r.preprocessLine(line)
-	if processedLine == "" {
-		return nil
-	}
-
-	if idx := strings.Index(processedLine, "<<"); idx != -1 {
-		state.accumulating = true
-		state.heredocMarker = strings.TrimSpace(processedLine[idx+2:])
-		state.currentCommand = strings.TrimSpace(processedLine[:idx])
-		return nil
-	}
-
-	state.executed++
-	finalCmd := r.replaceVariables(processedLine)
-	if err := r.dispatchCommand(ctx, i, finalCmd); err != nil {
-		return err
-	}
-	state.passed++
-	return nil
-}
-
-func (r *Runner) finalizeHeredoc(ctx context.Context, i int, state *runState) error {
-	content := strings.TrimSuffix(state.heredocContent.String(), "\n")
-
-	state.currentCommand = r.replaceVariables(state.currentCommand)
-	parts, err := r.parseArgs(state.currentCommand)
-	if err != nil {
-		return fmt.Errorf("line %d: failed to parse command prefix: %w", i+1, err)
-	}
-	parts = append(parts, content)
-
-	state.executed++
-	if err := r.dispatchParts(ctx, i, parts); err != nil {
-		return err
+func (r *Runner) printTestCase(tc TestCaseResult) {
+	switch tc.Status {
+	case StatusPassed:
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgTestCasePassed, tc.Name, tc.DurationMS/1000))
+	case StatusFailed:
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgTestCaseFailed, tc.Name, tc.DurationMS/1000, tc.Failure))
+	default:
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgTestCaseSkipped, tc.Name))
 	}
-	state.passed++
-	state.currentCommand = ""
-	state.heredocContent.Reset()
-	return nil
 }
 
-func (r *Runner) dispatchCommand(ctx context.Context, i int, line string) error {
-	parts, err := r.parseArgs(line)
-	if err != nil {
-		return fmt.Errorf("line %d: failed to parse command: %w", i+1, err)
-	}
-	return r.dispatchParts(ctx, i, parts)
+// commandNames lists the commands accepted by dispatchParts.
+var commandNames = []string{
+	"call_tool", "set_var", "input_var",
+	"assert_contains", "assert_equals", "assert_number", "assert_gt", "assert_string_length", "assert_error_code", "assert_path", "assert_schema", "assert_snapshot",
+	"assert_matches", "assert_lt", "assert_lte", "assert_gte", "assert_between", "assert_type", "assert_len", "assert_empty", "not",
+	"assert_is_error", "assert_not_error", "assert_content_count", "assert_content_type", "save_content",
+	"timeout", "expect_error", "ping", "logging", "rpc", "read_resource", "validate_input", "validate_output", "dump_vars",
 }
 
 func (r *Runner) dispatchParts(ctx context.Context, i int, parts []string) error {
@@ -166,6 +277,42 @@ func (r *Runner) dispatchParts(ctx context.Context, i int, parts []string) error
 		return r.handleAssertStringLengthCommand(i, parts)
 	case "assert_error_code":
 		return r.handleAssertErrorCodeCommand(i, parts)
+	case "assert_path":
+		return r.handleAssertPathCommand(i, parts)
+	case "assert_schema":
+		return r.handleAssertSchemaCommand(i, parts)
+	case "assert_snapshot":
+		return r.handleAssertSnapshotCommand(i, parts)
+	case "assert_matches":
+		return r.handleAssertMatchesCommand(i, parts)
+	case "assert_lt":
+		return r.handleAssertCompareCommand(i, parts, "<")
+	case "assert_lte":
+		return r.handleAssertCompareCommand(i, parts, "<=")
+	case "assert_gte":
+		return r.handleAssertCompareCommand(i, parts, ">=")
+	case "assert_between":
+		return r.handleAssertBetweenCommand(i, parts)
+	case "assert_type":
+		return r.handleAssertTypeCommand(i, parts)
+	case "assert_len":
+		return r.handleAssertLenCommand(i, parts)
+	case "assert_empty":
+		return r.handleAssertEmptyCommand(i, parts)
+	case "not":
+		return r.handleNotCommand(ctx, i, parts)
+	case "assert_is_error":
+		return r.handleAssertIsErrorCommand(i, parts, true)
+	case "assert_not_error":
+		return r.handleAssertIsErrorCommand(i, parts, false)
+	case "assert_content_count":
+		return r.handleAssertContentCountCommand(i, parts)
+	case "assert_content_type":
+		return r.handleAssertContentTypeCommand(i, parts)
+	case "save_content":
+		return r.handleSaveContentCommand(i, parts)
+	case "validate_input", "validate_output":
+		return r.handleValidateCommand(i, parts)
 	case "timeout":
 		return r.handleTimeoutCommand(ctx, i, parts)
 	case "expect_error":
@@ -174,6 +321,12 @@ func (r *Runner) dispatchParts(ctx context.Context, i int, parts []string) error
 		return r.handlePingCommand(ctx, i)
 	case "logging":
 		return r.handleLoggingCommand(ctx, i, parts)
+	case "rpc":
+		return r.handleRPCCommand(ctx, i, parts)
+	case "read_resource":
+		return r.handleReadResourceCommand(ctx, i, parts)
+	case "dump_vars":
+		return r.handleDumpVarsCommand(i, parts)
 	default:
 		return fmt.Errorf("line %d: unknown command: %s", i+1, cmd)
 	}
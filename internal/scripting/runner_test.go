@@ -8,9 +8,11 @@ import (
 
 func TestReplaceVariables(t *testing.T) {
 	r := &Runner{
-		variables: map[string]string{
-			"FOO": "bar",
-			"ID":  "123",
+		variables: map[string]any{
+			"FOO":   "bar",
+			"ID":    123.0,
+			"ID2":   "456",
+			"items": []any{"a", "b"},
 		},
 	}
 
@@ -23,12 +25,25 @@ func TestReplaceVariables(t *testing.T) {
 		{"no var here", "no var here"},
 		{"$FOO$ID", "bar123"},
 		{"mixed $FOO and $UNKNOWN", "mixed bar and $UNKNOWN"},
+		{"$ID2 $ID", "456 123"},
+		{"$IDx ${ID}x", "$IDx 123x"},
+		{"$.items[0] $", "$.items[0] $"},
+		{"list $items", `list ["a","b"]`},
+		{"sum ${ID + 1}", "sum 124"},
+		{"${upper(FOO) + '-' + len(items)}", "BAR-2"},
+		{`${"}" + FOO}`, "}bar"},
 	}
 
 	for _, tt := range tests {
-		result := r.replaceVariables(tt.input)
-		if result != tt.expected {
-			t.Errorf("replaceVariables(%q) = %q; want %q", tt.input, result, tt.expected)
+		result, err := r.replaceVariables(tt.input)
+		if err != nil || result != tt.expected {
+			t.Errorf("replaceVariables(%q) = %q, %v; want %q", tt.input, result, err, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"${missing}", "${ID +}", "${ID"} {
+		if _, err := r.replaceVariables(input); err == nil {
+			t.Errorf("replaceVariables(%q): want error", input)
 		}
 	}
 }
@@ -100,6 +115,49 @@ func TestConvertValue(t *testing.T) {
 	}
 }
 
+func TestExpandArgs(t *testing.T) {
+	obj := map[string]any{"a": 1.0}
+	r := &Runner{variables: map[string]any{"obj": obj, "n": 2.0, "s": "x"}}
+	parts, values, err := r.expandArgs([]string{"call_tool", "t", "data:$obj", "${n + 1}", "id:$s-1", "$missing", "http://$s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantParts := []string{"call_tool", "t", `data:{"a":1}`, "3", "id:x-1", "$missing", "http://x"}
+	if !reflect.DeepEqual(parts, wantParts) {
+		t.Errorf("parts = %q; want %q", parts, wantParts)
+	}
+	wantValues := []argValue{{}, {}, {value: obj, ok: true, keyed: true}, {value: 3.0, ok: true}, {keyed: true}, {}, {keyed: true}}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("values = %v; want %v", values, wantValues)
+	}
+}
+
+func TestArgumentValue(t *testing.T) {
+	obj := map[string]any{"a": 1.0}
+	tests := []struct {
+		text     string
+		value    argValue
+		schema   map[string]any
+		expected any
+	}{
+		{`{"a":1}`, argValue{value: obj, ok: true}, map[string]any{}, obj},
+		{`{"a":1}`, argValue{value: obj, ok: true}, map[string]any{"type": "object"}, obj},
+		{`{"a":1}`, argValue{value: obj, ok: true}, map[string]any{"type": "string"}, `{"a":1}`},
+		{"42", argValue{value: 42.0, ok: true}, map[string]any{"type": "string"}, "42"},
+		{"42", argValue{value: 42.0, ok: true}, nil, 42.0},
+		{"true", argValue{value: true, ok: true}, map[string]any{"type": []any{"boolean", "null"}}, true},
+		{"7", argValue{value: "7", ok: true}, map[string]any{"type": "integer"}, 7},
+		{"7", argValue{}, map[string]any{}, "7"},
+	}
+
+	for _, tt := range tests {
+		result := argumentValue(tt.text, tt.value, tt.schema)
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("argumentValue(%q, %v, %v) = %v (%T); want %v (%T)", tt.text, tt.value, tt.schema, result, result, tt.expected, tt.expected)
+		}
+	}
+}
+
 func TestParseComments(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,233 @@
+package scripting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// schemaError is a value that does not match a JSON Schema. message starts
+// with the schema keyword that failed, e.g. "required: ...".
+type schemaError struct {
+	pointer string // JSON pointer of the value in the instance, "" for the root
+	message string
+}
+
+func (e *schemaError) Error() string {
+	pointer := e.pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%s: %s", pointer, e.message)
+}
+
+// compileSchema resolves a JSON Schema given as decoded JSON or raw bytes.
+func compileSchema(schema any) (*jsonschema.Resolved, error) {
+	data, ok := schema.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(schema); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	rs, err := s.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return rs, nil
+}
+
+// validateSchema validates a decoded JSON value against the schema. A
+// mismatch is returned as *schemaError.
+func validateSchema(schema any, instance any) error {
+	rs, err := compileSchema(schema)
+	if err != nil {
+		return err
+	}
+	return validateResolved(rs, instance)
+}
+
+func validateResolved(rs *jsonschema.Resolved, instance any) error {
+	err := rs.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	locations, message := splitSchemaError(err)
+	if strings.HasPrefix(message, "unexpected additional properties") {
+		message = "additionalProperties: " + message
+	}
+	return &schemaError{
+		pointer: locateInstance(rs, instance, locations),
+		message: message,
+	}
+}
+
+// splitSchemaError splits a validation error of the form "validating root:
+// validating /properties/x: type: ..." into the schema locations and the
+// message of the failed keyword.
+//
+// jsonschema-go (v0.4.2) reports no instance location, only this text: every
+// schema that is validated wraps the error with "validating <location>: ",
+// where the location is the JSON pointer of the schema relative to the root,
+// "root" for the root itself, or the $id of a schema that has one. The
+// pointers in TestValidateSchema check that this format still holds after
+// an update of the library.
+func splitSchemaError(err error) (locations []string, message string) {
+	message = err.Error()
+	for strings.HasPrefix(message, "validating ") {
+		loc, rest, ok := strings.Cut(strings.TrimPrefix(message, "validating "), ": ")
+		if !ok {
+			break
+		}
+		if loc == "root" {
+			loc = ""
+		}
+		locations = append(locations, loc)
+		message = rest
+	}
+	return locations, message
+}
+
+// locateInstance follows the schema locations of a validation error through
+// the instance and returns the JSON pointer of the failing value. Where the
+// schema does not name the child, e.g. for "items", each child is validated
+// on its own to find the one that fails.
+func locateInstance(rs *jsonschema.Resolved, instance any, locations []string) string {
+	var tokens []string
+	current := instance
+	for i := 1; i < len(locations); i++ {
+		prev, next := locations[i-1], locations[i]
+		if !strings.HasPrefix(next, prev+"/") {
+			continue // a $ref to another part of the schema
+		}
+		segs := strings.Split(next[len(prev)+1:], "/")
+		var key string
+		switch {
+		case len(segs) == 2 && segs[0] == "properties":
+			key = unescapePointer(segs[1])
+		case len(segs) == 2 && (segs[0] == "prefixItems" || segs[0] == "items"):
+			key = segs[1]
+		case len(segs) == 1 && (segs[0] == "items" || segs[0] == "additionalItems" || segs[0] == "additionalProperties"),
+			len(segs) == 2 && segs[0] == "patternProperties":
+			key = findFailingChild(rs, instance, tokens, current, next)
+		default:
+			continue // allOf, anyOf, if/then/else and others apply to the same value
+		}
+		if key == "" {
+			break
+		}
+		child, ok := childValue(current, key)
+		if !ok {
+			break
+		}
+		tokens = append(tokens, key)
+		current = child
+	}
+	if len(tokens) == 0 {
+		return ""
+	}
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(t)
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+// findFailingChild returns the key or index of the first child of container
+// that still fails at schema location loc when it is the only child. Each try
+// validates the root with the container cut down to one child, so the cost
+// grows with the number of children times the size of the rest of the root;
+// it only runs for a value that already failed.
+func findFailingChild(rs *jsonschema.Resolved, root any, tokens []string, container any, loc string) string {
+	try := func(only any) bool {
+		err := rs.Validate(replaceAt(root, tokens, only))
+		if err == nil {
+			return false
+		}
+		locations, _ := splitSchemaError(err)
+		for _, l := range locations {
+			if l == loc {
+				return true
+			}
+		}
+		return false
+	}
+	switch c := container.(type) {
+	case []any:
+		for i, item := range c {
+			if try([]any{item}) {
+				return strconv.Itoa(i)
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(c) {
+			if try(map[string]any{k: c[k]}) {
+				return k
+			}
+		}
+	}
+	return ""
+}
+
+// replaceAt returns a copy of root with the value at tokens replaced.
+func replaceAt(root any, tokens []string, value any) any {
+	if len(tokens) == 0 {
+		return value
+	}
+	switch c := root.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(c))
+		for k, v := range c {
+			out[k] = v
+		}
+		out[tokens[0]] = replaceAt(c[tokens[0]], tokens[1:], value)
+		return out
+	case []any:
+		out := append([]any(nil), c...)
+		if i, err := strconv.Atoi(tokens[0]); err == nil && i >= 0 && i < len(out) {
+			out[i] = replaceAt(out[i], tokens[1:], value)
+		}
+		return out
+	}
+	return root
+}
+
+func childValue(v any, key string) (any, bool) {
+	switch c := v.(type) {
+	case map[string]any:
+		child, ok := c[key]
+		return child, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, false
+		}
+		return c[i], true
+	}
+	return nil, false
+}
+
+func unescapePointer(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
+
+// loadSchemaFile reads a JSON Schema file. Relative paths are resolved
+// against the directory of the script.
+func (r *Runner) loadSchemaFile(path string) ([]byte, error) {
+	if !filepath.IsAbs(path) && r.dir != "" {
+		path = filepath.Join(r.dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return data, nil
+}
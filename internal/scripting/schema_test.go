@@ -0,0 +1,116 @@
+package scripting
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]any{
+		"type":     "object",
+		"required": []any{"id"},
+		"properties": map[string]any{
+			"id":    map[string]any{"type": "integer"},
+			"items": map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/item"}},
+			"meta":  map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+		},
+		"$defs": map[string]any{
+			"item": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"n": map[string]any{"type": "integer", "minimum": 0}},
+			},
+		},
+	}
+	tests := []struct {
+		instance string
+		want     string
+	}{
+		{`{"id": 1, "items": [{"n": 1}], "meta": {"a": "b"}}`, ""},
+		{`{"items": []}`, `/: required: missing properties: ["id"]`},
+		{`{"id": "x"}`, `/id: type:`},
+		{`{"id": 1, "items": [{"n": 1}, {"n": 2}, {"n": -1}]}`, `/items/2/n: minimum:`},
+		{`{"id": 1, "meta": {"a": "b", "c": 3}}`, `/meta/c: type:`},
+	}
+	for _, tt := range tests {
+		var instance any
+		if err := json.Unmarshal([]byte(tt.instance), &instance); err != nil {
+			t.Fatal(err)
+		}
+		err := validateSchema(schema, instance)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("validateSchema(%s) = %v; want nil", tt.instance, err)
+		case tt.want != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.want)):
+			t.Errorf("validateSchema(%s) = %v; want %q...", tt.instance, err, tt.want)
+		}
+	}
+}
+
+func TestAssertSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "list.json"), []byte(`{"type":"object","required":["tools"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &mcp.Tool{Name: "count", OutputSchema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"count": map[string]any{"type": "integer"}},
+	}}
+	r := &Runner{dir: dir}
+
+	r.lastRawMap = map[string]any{"structuredContent": map[string]any{"count": 3.0}}
+	r.lastTool = tool
+	if err := r.handleAssertSchemaCommand(0, []string{"assert_schema"}); err != nil {
+		t.Errorf("valid result: %v", err)
+	}
+	r.lastRawMap = map[string]any{"structuredContent": map[string]any{"count": "three"}}
+	err := r.handleAssertSchemaCommand(0, []string{"assert_schema"})
+	if err == nil || !strings.Contains(err.Error(), "does not match its outputSchema: /count: type:") {
+		t.Errorf("invalid result: %v", err)
+	}
+	r.lastRawMap = map[string]any{"isError": true, "content": []any{}}
+	if err := r.checkOutputSchema(); err != nil {
+		t.Errorf("error results are not validated, got %v", err)
+	}
+
+	r.lastRawMap = map[string]any{"tools": []any{}}
+	r.lastTool = nil
+	if err := r.handleAssertSchemaCommand(0, []string{"assert_schema", "list.json"}); err != nil {
+		t.Errorf("schema file: %v", err)
+	}
+	r.lastRawMap = map[string]any{"resources": []any{}}
+	err = r.handleAssertSchemaCommand(0, []string{"assert_schema", "list.json"})
+	if err == nil || !strings.Contains(err.Error(), "does not match list.json: /: required") {
+		t.Errorf("schema file mismatch: %v", err)
+	}
+	if err := r.handleAssertSchemaCommand(0, []string{"assert_schema"}); err == nil {
+		t.Error("no outputSchema: want error")
+	}
+}
+
+func TestCheckInputSchema(t *testing.T) {
+	tool := &mcp.Tool{Name: "add", InputSchema: map[string]any{
+		"type":     "object",
+		"required": []any{"a", "b"},
+		"properties": map[string]any{
+			"a": map[string]any{"type": "integer"},
+			"b": map[string]any{"type": "integer"},
+		},
+	}}
+	if err := checkInputSchema(tool, map[string]any{"a": 1, "b": 2}); err != nil {
+		t.Errorf("valid arguments: %v", err)
+	}
+	err := checkInputSchema(tool, map[string]any{"a": 1})
+	if err == nil || !strings.Contains(err.Error(), `do not match its inputSchema: /: required: missing properties: ["b"]`) {
+		t.Errorf("missing argument: %v", err)
+	}
+	// convertValue leaves values that do not fit the schema as strings.
+	err = checkInputSchema(tool, map[string]any{"a": 1, "b": convertValue("two", map[string]any{"type": "integer"})})
+	if err == nil || !strings.Contains(err.Error(), "/b: type:") {
+		t.Errorf("wrong type: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package scripting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/i18n"
+)
+
+// snapshotDir is the directory next to the script that holds the snapshots.
+const snapshotDir = "__snapshots__"
+
+// ignoredValue replaces the values of ignore paths in snapshots.
+const ignoredValue = "<ignored>"
+
+// maxDiffLines limits the differences listed for a failed snapshot.
+const maxDiffLines = 20
+
+// handleAssertSnapshotCommand compares the last response with a stored
+// snapshot: assert_snapshot <name> [ignorePath...]. A missing snapshot is
+// written, a different one is replaced if Runner.UpdateSnapshots is set.
+func (r *Runner) handleAssertSnapshotCommand(lineIdx int, parts []string) error {
+	if len(parts) < 2 {
+		return fmt.Errorf("line %d: assert_snapshot expects <name> [ignorePath...]", lineIdx+1)
+	}
+	name := parts[1]
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("line %d: invalid snapshot name %q", lineIdx+1, name)
+	}
+	if r.lastRawMap == nil {
+		return fmt.Errorf("line %d: assert_snapshot: no previous response available", lineIdx+1)
+	}
+
+	var actual any = r.lastRawMap
+	for _, expr := range parts[2:] {
+		p, err := r.ignorePath(expr)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineIdx+1, err)
+		}
+		actual = p.replace(actual, ignoredValue)
+	}
+	data, err := encodeJSON(actual, "  ")
+	if err != nil {
+		return fmt.Errorf("line %d: failed to encode snapshot: %w", lineIdx+1, err)
+	}
+
+	path := r.snapshotPath(name)
+	stored, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := writeSnapshot(path, data); err != nil {
+			return fmt.Errorf("line %d: %w", lineIdx+1, err)
+		}
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgSnapshotWritten, name, path))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("line %d: failed to read snapshot: %w", lineIdx+1, err)
+	}
+
+	var expected any
+	if err := json.Unmarshal(stored, &expected); err != nil {
+		return fmt.Errorf("line %d: invalid snapshot %s: %w", lineIdx+1, path, err)
+	}
+	// Compare decoded values, so the stored file may be formatted freely.
+	var got any
+	_ = json.Unmarshal(data, &got)
+	diff := diffJSON("$", expected, got)
+	if len(diff) == 0 {
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgAssertionPassed, fmt.Sprintf("response matches snapshot %s", name)))
+		return nil
+	}
+	if r.UpdateSnapshots {
+		if err := writeSnapshot(path, data); err != nil {
+			return fmt.Errorf("line %d: %w", lineIdx+1, err)
+		}
+		fmt.Fprint(r.out(), i18n.T(i18n.MsgSnapshotUpdated, name, path))
+		return nil
+	}
+
+	if len(diff) > maxDiffLines {
+		diff = append(diff[:maxDiffLines], fmt.Sprintf("... and %d more", len(diff)-maxDiffLines))
+	}
+	return assertionFailed(lineIdx, "response does not match snapshot %s (%s):\n  %s\nrun with --update-snapshots to accept the new response", name, path, strings.Join(diff, "\n  "))
+}
+
+// ignorePath resolves an ignore path against the last response as
+// extractValue does: on the whole response, without a leading
+// "structuredContent." and inside structuredContent. The first form that
+// matches is returned; a path that matches nothing is an error, as it most
+// likely has a typo.
+func (r *Runner) ignorePath(expr string) (*jsonPath, error) {
+	p, err := compilePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	forms := []*jsonPath{p}
+	if len(p.steps) > 0 && p.steps[0].kind == stepKey && p.steps[0].key == "structuredContent" && !p.steps[0].recursive {
+		forms = append(forms, &jsonPath{expr: p.expr, steps: p.steps[1:]})
+	}
+	if _, ok := r.lastRawMap["structuredContent"]; ok {
+		steps := append([]pathStep{{kind: stepKey, key: "structuredContent"}}, p.steps...)
+		forms = append(forms, &jsonPath{expr: p.expr, steps: steps})
+	}
+	for _, q := range forms {
+		if len(q.query(r.lastRawMap)) > 0 {
+			return q, nil
+		}
+	}
+	return nil, fmt.Errorf("ignore path %s matches nothing in the response", expr)
+}
+
+// snapshotPath returns the file of a snapshot: __snapshots__/<script>/<name>.json
+// next to the script, or __snapshots__/<name>.json for scripts without a file.
+func (r *Runner) snapshotPath(name string) string {
+	dir := filepath.Join(r.dir, snapshotDir)
+	if r.script != "" {
+		base := filepath.Base(r.script)
+		dir = filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+func writeSnapshot(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// diffJSON returns the differences between two decoded JSON values, one line
+// per changed, removed or added value, labeled with its path.
+func diffJSON(path string, expected, actual any) []string {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range e {
+			keys[k] = true
+		}
+		for k := range a {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diff []string
+		for _, k := range sorted {
+			child := childPath(path, k)
+			ev, inE := e[k]
+			av, inA := a[k]
+			switch {
+			case !inA:
+				diff = append(diff, fmt.Sprintf("- %s: %s", child, compactJSON(ev)))
+			case !inE:
+				diff = append(diff, fmt.Sprintf("+ %s: %s", child, compactJSON(av)))
+			default:
+				diff = append(diff, diffJSON(child, ev, av)...)
+			}
+		}
+		return diff
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			break
+		}
+		var diff []string
+		for i := 0; i < max(len(e), len(a)); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(a):
+				diff = append(diff, fmt.Sprintf("- %s: %s", child, compactJSON(e[i])))
+			case i >= len(e):
+				diff = append(diff, fmt.Sprintf("+ %s: %s", child, compactJSON(a[i])))
+			default:
+				diff = append(diff, diffJSON(child, e[i], a[i])...)
+			}
+		}
+		return diff
+	default:
+		if expected == actual {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("~ %s: %s -> %s", path, compactJSON(expected), compactJSON(actual))}
+}
+
+func childPath(path, key string) string {
+	if isIdentifier(key) {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%q]", path, key)
+}
+
+// encodeJSON encodes v without escaping <, > and &, which are common in
+// tool output, followed by a newline.
+func encodeJSON(v any, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compactJSON returns v as JSON on one line, shortened if it is long.
+func compactJSON(v any) string {
+	b, _ := encodeJSON(v, "")
+	s := strings.TrimSuffix(string(b), "\n")
+	if r := []rune(s); len(r) > 80 {
+		s = string(r[:77]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package scripting
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONPathReplace(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"id": 1, "items": [{"ts": 1, "n": 1}, {"ts": 2, "n": 2}], "meta": {"ts": 3}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$.id", `{"id":"x","items":[{"n":1,"ts":1},{"n":2,"ts":2}],"meta":{"ts":3}}`},
+		{"$.items[*].ts", `{"id":1,"items":[{"n":1,"ts":"x"},{"n":2,"ts":"x"}],"meta":{"ts":3}}`},
+		{"$..ts", `{"id":1,"items":[{"n":1,"ts":"x"},{"n":2,"ts":"x"}],"meta":{"ts":"x"}}`},
+		{"$.items[1]", `{"id":1,"items":[{"n":1,"ts":1},"x"],"meta":{"ts":3}}`},
+		{"$.missing", `{"id":1,"items":[{"n":1,"ts":1},{"n":2,"ts":2}],"meta":{"ts":3}}`},
+	}
+	for _, tt := range tests {
+		p, err := compilePath(tt.path)
+		if err != nil {
+			t.Fatalf("compilePath(%q): %v", tt.path, err)
+		}
+		got, _ := json.Marshal(p.replace(doc, "x"))
+		if string(got) != tt.want {
+			t.Errorf("replace(%q) = %s; want %s", tt.path, got, tt.want)
+		}
+	}
+	// The original document is not modified.
+	if got, _ := json.Marshal(doc); !strings.Contains(string(got), `"id":1`) {
+		t.Errorf("replace modified the document: %s", got)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	var expected, actual any
+	_ = json.Unmarshal([]byte(`{"a": 1, "b": {"c": "x"}, "list": [1, 2], "gone": true}`), &expected)
+	_ = json.Unmarshal([]byte(`{"a": 2, "b": {"c": "x"}, "list": [1, 2, 3], "new key": null}`), &actual)
+	want := []string{
+		`~ $.a: 1 -> 2`,
+		`- $.gone: true`,
+		`+ $.list[2]: 3`,
+		`+ $["new key"]: null`,
+	}
+	if got := diffJSON("$", expected, actual); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffJSON = %q; want %q", got, want)
+	}
+	if got := diffJSON("$", expected, expected); got != nil {
+		t.Errorf("diffJSON of equal values = %q", got)
+	}
+}
+
+func TestAssertSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	r := &Runner{dir: dir, script: filepath.Join(dir, "users.mcp"), Output: &out}
+	file := filepath.Join(dir, "__snapshots__", "users", "list.json")
+	cmd := []string{"assert_snapshot", "list", "$.meta.requestId"}
+
+	r.lastRawMap = map[string]any{"users": []any{"ann"}, "meta": map[string]any{"requestId": "a1"}}
+	if err := r.handleAssertSnapshotCommand(0, cmd); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"requestId": "<ignored>"`) {
+		t.Errorf("ignore path not applied:\n%s", data)
+	}
+
+	// Ignored fields may change.
+	r.lastRawMap = map[string]any{"users": []any{"ann"}, "meta": map[string]any{"requestId": "b2"}}
+	if err := r.handleAssertSnapshotCommand(0, cmd); err != nil {
+		t.Errorf("ignored change: %v", err)
+	}
+
+	r.lastRawMap = map[string]any{"users": []any{"bob"}, "meta": map[string]any{"requestId": "c3"}}
+	err = r.handleAssertSnapshotCommand(0, cmd)
+	if err == nil || !strings.Contains(err.Error(), `~ $.users[0]: "ann" -> "bob"`) {
+		t.Errorf("changed response: %v", err)
+	}
+
+	r.UpdateSnapshots = true
+	if err := r.handleAssertSnapshotCommand(0, cmd); err != nil {
+		t.Errorf("update: %v", err)
+	}
+	r.UpdateSnapshots = false
+	if err := r.handleAssertSnapshotCommand(0, cmd); err != nil {
+		t.Errorf("after update: %v", err)
+	}
+
+	if err := r.handleAssertSnapshotCommand(0, []string{"assert_snapshot", "../x"}); err == nil {
+		t.Error("invalid name: want error")
+	}
+
+	// Ignore paths may leave out "structuredContent.", as other paths may,
+	// and must match something.
+	r.lastRawMap = map[string]any{"structuredContent": map[string]any{"id": 7.0, "name": "x"}}
+	if err := r.handleAssertSnapshotCommand(0, []string{"assert_snapshot", "item", "id"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "__snapshots__", "users", "item.json"))
+	if !strings.Contains(string(data), `"id": "<ignored>"`) {
+		t.Errorf("ignore path inside structuredContent not applied:\n%s", data)
+	}
+	err = r.handleAssertSnapshotCommand(0, []string{"assert_snapshot", "item", "$.meta.requestID"})
+	if err == nil || !strings.Contains(err.Error(), "matches nothing") {
+		t.Errorf("ignore path without match: %v", err)
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/hmsoft0815/mlc_mcptester/internal/client"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -16,32 +16,47 @@ func (r *Runner) parseArgs(line string) ([]string, error) {
 	var parts []string
 	var current strings.Builder
 	inQuotes := false
+	quoted := false // the current argument has quotes, so "" is kept
 	var quoteChar rune
 
-	for _, char := range line {
+	for i := 0; i < len(line); i++ {
+		// An expression is kept as it is, including its quotes and spaces.
+		if strings.HasPrefix(line[i:], "${") {
+			if end := exprEnd(line[i:]); end >= 0 {
+				current.WriteString(line[i : i+end+1])
+				i += end
+				continue
+			}
+		}
+		char, size := utf8.DecodeRuneInString(line[i:])
+		i += size - 1
 		switch {
 		case (char == '"' || char == '\'') && !inQuotes:
 			inQuotes = true
+			quoted = true
 			quoteChar = char
 		case char == quoteChar && inQuotes:
 			inQuotes = false
 		case char == ' ' && !inQuotes:
-			if current.Len() > 0 {
+			if current.Len() > 0 || quoted {
 				parts = append(parts, current.String())
 				current.Reset()
+				quoted = false
 			}
 		default:
 			current.WriteRune(char)
 		}
 	}
-	if current.Len() > 0 {
+	if current.Len() > 0 || quoted {
 		parts = append(parts, current.String())
 	}
 	return parts, nil
 }
 
 // callToolPositional calls the tool with the given name and arguments.
-func (r *Runner) callToolPositional(ctx context.Context, name string, args []string) error {
+// values holds the typed values of args that are a single variable or
+// expression and may be nil.
+func (r *Runner) callToolPositional(ctx context.Context, name string, args []string, values []argValue) error {
 	tools, err := r.session.ListTools(ctx, nil)
 	if err != nil {
 		return err
@@ -74,22 +89,25 @@ func (r *Runner) callToolPositional(ctx context.Context, name string, args []str
 	}
 	sort.Strings(propNames)
 
+	if len(values) != len(args) {
+		values = make([]argValue, len(args))
+	}
 	toolArgs := make(map[string]any)
-	var positionalArgs []string
+	var positionalArgs []int
 
 	// First pass: extract named arguments and collect positional ones
-	for _, arg := range args {
+	for i, arg := range args {
 		if strings.Contains(arg, ":") {
 			parts := strings.SplitN(arg, ":", 2)
 			key := parts[0]
 			val := parts[1]
 			if propSchema, ok := properties[key].(map[string]any); ok {
-				toolArgs[key] = convertValue(val, propSchema)
+				toolArgs[key] = argumentValue(val, values[i], propSchema)
 				continue
 			}
 		}
 		// If not a named arg OR the key doesn't exist, treat as positional
-		positionalArgs = append(positionalArgs, arg)
+		positionalArgs = append(positionalArgs, i)
 	}
 
 	// Second pass: fill remaining properties with positional arguments
@@ -100,12 +118,39 @@ func (r *Runner) callToolPositional(ctx context.Context, name string, args []str
 		}
 		if posIdx < len(positionalArgs) {
 			propSchema, _ := properties[propName].(map[string]any)
-			toolArgs[propName] = convertValue(positionalArgs[posIdx], propSchema)
+			i := positionalArgs[posIdx]
+			v := values[i]
+			if v.keyed {
+				// The key is not a property, so the whole argument is the value.
+				v = argValue{}
+			}
+			toolArgs[propName] = argumentValue(args[i], v, propSchema)
 			posIdx++
 		}
 	}
 
-	return r.call(ctx, name, toolArgs)
+	if r.ValidateInput && targetTool != nil {
+		if err := checkInputSchema(targetTool, toolArgs); err != nil {
+			return err
+		}
+	}
+	return r.call(ctx, targetTool, name, toolArgs)
+}
+
+// argumentValue returns the value of a tool argument. The value of a single
+// variable or expression keeps its JSON type, unless it is passed to a string
+// property. Other values are converted by convertValue.
+func argumentValue(text string, v argValue, schema map[string]any) any {
+	if !v.ok {
+		return convertValue(text, schema)
+	}
+	if s, ok := v.value.(string); ok {
+		return convertValue(s, schema)
+	}
+	if typeName, _ := schema["type"].(string); typeName == "string" {
+		return text
+	}
+	return v.value
 }
 
 // convertValue converts a string value to the type specified in the schema.
@@ -140,7 +185,7 @@ func convertValue(val string, schema map[string]any) any {
 }
 
 // call calls the tool with the given name and arguments.
-func (r *Runner) call(ctx context.Context, name string, args map[string]any) error {
+func (r *Runner) call(ctx context.Context, tool *mcp.Tool, name string, args map[string]any) error {
 	var rawResponse map[string]any
 	var text string
 	var err error
@@ -156,13 +201,59 @@ func (r *Runner) call(ctx context.Context, name string, args map[string]any) err
 	}
 
 	r.updateState(rawResponse, text)
+	r.lastTool = tool
+	if r.ValidateOutput {
+		return r.checkOutputSchema()
+	}
+	return nil
+}
+
+// checkInputSchema validates the arguments of a tool call against the
+// inputSchema of the tool.
+func checkInputSchema(tool *mcp.Tool, args map[string]any) error {
+	if tool.InputSchema == nil {
+		return nil
+	}
+	// Validate the arguments as the server will see them.
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for tool %s: %w", tool.Name, err)
+	}
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return fmt.Errorf("invalid arguments for tool %s: %w", tool.Name, err)
+	}
+	if err := validateSchema(tool.InputSchema, instance); err != nil {
+		return fmt.Errorf("arguments for tool %s do not match its inputSchema: %w (not sent; use validate_input off or --no-validate to send them anyway)", tool.Name, err)
+	}
 	return nil
 }
 
-// executeRawCall calls the tool with the given name and arguments using the raw call method.
+// checkOutputSchema validates the structuredContent of the last tool result
+// against the outputSchema of the tool. Tools without an outputSchema and
+// error results are not checked.
+func (r *Runner) checkOutputSchema() error {
+	tool := r.lastTool
+	if tool == nil || tool.OutputSchema == nil {
+		return nil
+	}
+	if isError, _ := r.lastRawMap["isError"].(bool); isError {
+		return nil
+	}
+	structured, ok := r.lastRawMap["structuredContent"]
+	if !ok {
+		return fmt.Errorf("tool %s declares an outputSchema but returned no structuredContent", tool.Name)
+	}
+	if err := validateSchema(tool.OutputSchema, structured); err != nil {
+		return fmt.Errorf("result of tool %s does not match its outputSchema: %w", tool.Name, err)
+	}
+	return nil
+}
+
+// executeRawCall calls the tool with the given name and arguments using the raw client.
 func (r *Runner) executeRawCall(ctx context.Context, name string, args map[string]any) (map[string]any, string, error) {
 	meta := map[string]any{"progressToken": fmt.Sprintf("script-progress-%s", name)}
-	rawResponse, err := client.CallToolRaw(ctx, r.session, name, args, meta)
+	rawResponse, err := r.rpc.CallTool(ctx, name, args, meta)
 	if err != nil {
 		return nil, "", err
 	}
@@ -174,7 +265,7 @@ func (r *Runner) executeRawCall(ctx context.Context, name string, args map[strin
 // executeSDKCall calls the tool with the given name and arguments using the SDK call method.
 func (r *Runner) executeSDKCall(ctx context.Context, name string, args map[string]any) (map[string]any, string, error) {
 	meta := map[string]any{"progressToken": fmt.Sprintf("script-progress-%s", name)}
-	rawResponse, err := client.CallToolRaw(ctx, r.session, name, args, meta)
+	rawResponse, err := r.rpc.CallTool(ctx, name, args, meta)
 	if err != nil {
 		return nil, "", err
 	}
@@ -210,12 +301,23 @@ func (r *Runner) processSDKResult(result *mcp.CallToolResult) string {
 	for _, content := range result.Content {
 		switch c := content.(type) {
 		case *mcp.TextContent:
-			fmt.Printf("Response: %s\n", c.Text)
+			fmt.Fprintf(r.out(), "Response: %s\n", c.Text)
 			textBuilder.WriteString(c.Text)
 		case *mcp.ImageContent:
-			fmt.Printf("Response: [Image data, size %d]\n", len(c.Data))
+			fmt.Fprintf(r.out(), "Response: [Image data, size %d]\n", len(c.Data))
+		case *mcp.AudioContent:
+			fmt.Fprintf(r.out(), "Response: [Audio data, %s, size %d]\n", c.MIMEType, len(c.Data))
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil {
+				fmt.Fprintf(r.out(), "Response: [Resource %s]\n", c.Resource.URI)
+			}
+		case *mcp.ResourceLink:
+			fmt.Fprintf(r.out(), "Response: [Resource link %s]\n", c.URI)
 		}
 	}
+	if result.IsError {
+		fmt.Fprintln(r.out(), "Response: the tool reported an error (isError)")
+	}
 	return textBuilder.String()
 }
 
@@ -223,9 +325,10 @@ func (r *Runner) processSDKResult(result *mcp.CallToolResult) string {
 func (r *Runner) updateState(rawResponse map[string]any, text string) {
 	r.lastText = text
 	r.lastRawMap = rawResponse
+	r.lastTool = nil
 	respData, _ := json.MarshalIndent(rawResponse, "", "  ")
 	r.lastResponse = string(respData)
 	if r.Raw {
-		fmt.Printf("Raw Response:\n%s\n", r.lastResponse)
+		fmt.Fprintf(r.out(), "Raw Response:\n%s\n", r.lastResponse)
 	}
 }
@@ -2,73 +2,364 @@ package scripting
 
 import (
 	"fmt"
-	"strconv"
+	"io"
+	"sort"
 	"strings"
 )
 
-func (r *Runner) replaceVariables(line string) string {
-	for name, val := range r.variables {
-		line = strings.ReplaceAll(line, "$"+name, val)
+// scope holds the local variables of a for loop or function call.
+type scope struct {
+	vars     map[string]any
+	function bool // the scopes of the caller are not visible in a function
+}
+
+// pushScope opens a scope for local variables. Functions see only their own
+// scopes and the script variables, not those of the caller.
+func (r *Runner) pushScope(function bool) {
+	r.scopes = append(r.scopes, &scope{vars: make(map[string]any), function: function})
+}
+
+func (r *Runner) popScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// visibleScopes returns the local scopes that are searched for a variable,
+// innermost first. The script variables come after them.
+func (r *Runner) visibleScopes() []*scope {
+	var visible []*scope
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		visible = append(visible, r.scopes[i])
+		if r.scopes[i].function {
+			break
+		}
 	}
-	return line
+	return visible
 }
 
-// extractValue finds a value in the last response using dot notation (e.g., "structuredContent.0.id")
-func (r *Runner) extractValue(path string) (any, error) {
-	if path == "rawResponse" {
-		return r.lastResponse, nil
+// lookupVar returns the value of the innermost visible variable name.
+func (r *Runner) lookupVar(name string) (any, bool) {
+	for _, s := range r.visibleScopes() {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
 	}
+	v, ok := r.variables[name]
+	return v, ok
+}
 
-	if r.lastRawMap == nil {
-		return nil, fmt.Errorf("no previous response available")
+// setVar assigns to the innermost visible variable name, or creates a script
+// variable, so values set in loops and functions stay available afterwards.
+func (r *Runner) setVar(name string, value any) {
+	for _, s := range r.visibleScopes() {
+		if _, ok := s.vars[name]; ok {
+			s.vars[name] = value
+			return
+		}
 	}
+	if r.variables == nil {
+		r.variables = make(map[string]any)
+	}
+	r.variables[name] = value
+}
 
-	// 1. Try resolving the path as-is (works for nested structuredContent or top-level $. fields if literally present)
-	if val, err := r.resolvePath(path); err == nil {
-		return val, nil
+// letVar assigns to the innermost visible variable name, or creates it in
+// the innermost scope.
+func (r *Runner) letVar(name string, value any) {
+	if _, ok := r.lookupVar(name); ok || len(r.scopes) == 0 {
+		r.setVar(name, value)
+		return
 	}
+	r.bindVar(name, value)
+}
 
-	// 2. Try stripping "virtual" prefixes
-	trimmedPath := strings.TrimPrefix(path, "structuredContent.")
-	trimmedPath = strings.TrimPrefix(trimmedPath, "$.")
+// bindVar creates a variable in the innermost scope, hiding variables of the
+// same name outside of it.
+func (r *Runner) bindVar(name string, value any) {
+	r.scopes[len(r.scopes)-1].vars[name] = value
+}
 
-	if trimmedPath != path {
-		if val, err := r.resolvePath(trimmedPath); err == nil {
-			return val, nil
+// evalString parses and evaluates an expression.
+func (r *Runner) evalString(src string) (any, error) {
+	x, err := parseExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	return evalExpr(x, r.lookupVar)
+}
+
+// replaceVariables substitutes $name and ${expr} in line. $name takes the
+// longest identifier, so $id2 is never read as $id followed by "2". Undefined
+// $name references are left as they are, since $ also starts JSONPaths; in
+// ${expr} they are an error.
+func (r *Runner) replaceVariables(line string) (string, error) {
+	if !strings.Contains(line, "$") {
+		return line, nil
+	}
+	var b strings.Builder
+	err := scanReferences(line, func(text string, ref string, expr bool) error {
+		switch {
+		case expr:
+			v, err := r.evalString(ref)
+			if err != nil {
+				return err
+			}
+			b.WriteString(formatValue(v))
+		case ref != "":
+			if v, ok := r.lookupVar(ref); ok {
+				b.WriteString(formatValue(v))
+			} else {
+				b.WriteString(text)
+			}
+		default:
+			b.WriteString(text)
+		}
+		return nil
+	})
+	return b.String(), err
+}
+
+// argValue is the value of an argument that is a single $name or ${expr},
+// optionally after "key:". ok is false for other arguments.
+type argValue struct {
+	value any
+	ok    bool
+	keyed bool // the value follows "key:"
+}
+
+// expandArgs substitutes variables in each argument of a command. Values are
+// inserted after the line is split, so quotes and spaces in them are kept.
+// For arguments that are a single reference the value is also returned with
+// its JSON type.
+func (r *Runner) expandArgs(parts []string) ([]string, []argValue, error) {
+	out := make([]string, len(parts))
+	values := make([]argValue, len(parts))
+	for i, p := range parts {
+		text, err := r.replaceVariables(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		out[i] = text
+		ref, keyed := p, false
+		if key, val, ok := strings.Cut(p, ":"); ok && isIdentifier(key) {
+			ref, keyed = val, true
 		}
+		if values[i], err = r.referenceValue(ref); err != nil {
+			return nil, nil, err
+		}
+		values[i].keyed = keyed
 	}
+	return out, values, nil
+}
 
-	return nil, fmt.Errorf("path %q not found", path)
+// referenceValue returns the value of s if it is a single $name of a defined
+// variable or a single ${expr}.
+func (r *Runner) referenceValue(s string) (argValue, error) {
+	switch {
+	case strings.HasPrefix(s, "${") && exprEnd(s) == len(s)-1:
+		v, err := r.evalString(strings.TrimSpace(s[2 : len(s)-1]))
+		if err != nil {
+			return argValue{}, err
+		}
+		return argValue{value: v, ok: true}, nil
+	case strings.HasPrefix(s, "$") && identLen(s[1:]) == len(s)-1:
+		v, ok := r.lookupVar(s[1:])
+		return argValue{value: v, ok: ok}, nil
+	}
+	return argValue{}, nil
 }
 
-func (r *Runner) resolvePath(path string) (any, error) {
-	parts := strings.Split(path, ".")
-	var current any = r.lastRawMap
+// argValuesFor returns the values of parts, which is the current command or
+// a command nested in it, such as the command of timeout or expect_error.
+func (r *Runner) argValuesFor(parts []string) []argValue {
+	if len(r.argValues) < len(parts) {
+		return nil
+	}
+	return r.argValues[len(r.argValues)-len(parts):]
+}
 
-	for _, part := range parts {
-		if part == "" || part == "$" {
+// varRefs returns the variables referenced in line by $name and inside
+// ${expr}.
+func varRefs(line string) ([]string, error) {
+	var names []string
+	err := scanReferences(line, func(_ string, ref string, expr bool) error {
+		if !expr {
+			if ref != "" {
+				names = append(names, ref)
+			}
+			return nil
+		}
+		x, err := parseExpr(ref)
+		if err != nil {
+			return err
+		}
+		names = append(names, exprVars(x)...)
+		return nil
+	})
+	return names, err
+}
+
+// scanReferences splits line into plain text, $name references and ${expr}
+// expressions and calls fn for each piece in order. text is the piece as
+// written; ref is the variable name or the expression, "" for plain text.
+func scanReferences(line string, fn func(text, ref string, expr bool) error) error {
+	for line != "" {
+		i := strings.IndexByte(line, '$')
+		if i < 0 {
+			return fn(line, "", false)
+		}
+		if i > 0 {
+			if err := fn(line[:i], "", false); err != nil {
+				return err
+			}
+			line = line[i:]
+		}
+		if strings.HasPrefix(line, "${") {
+			end := exprEnd(line)
+			if end < 0 {
+				return fmt.Errorf("missing } in %s", line)
+			}
+			if err := fn(line[:end+1], strings.TrimSpace(line[2:end]), true); err != nil {
+				return err
+			}
+			line = line[end+1:]
 			continue
 		}
+		n := identLen(line[1:])
+		if err := fn(line[:n+1], line[1:n+1], false); err != nil {
+			return err
+		}
+		line = line[n+1:]
+	}
+	return nil
+}
 
-		switch v := current.(type) {
-		case map[string]any:
-			var ok bool
-			current, ok = v[part]
-			if !ok {
-				return nil, fmt.Errorf("key %q not found", part)
+// exprEnd returns the index of the } that closes the ${ at the start of s,
+// skipping braces in nested ${ } and string literals, or -1.
+func exprEnd(s string) int {
+	depth := 0
+	var quote byte
+	for i := 2; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
 			}
-		case []any:
-			idx, err := strconv.Atoi(part)
-			if err != nil || idx < 0 || idx >= len(v) {
-				return nil, fmt.Errorf("invalid array index %q", part)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				return i
 			}
-			current = v[idx]
-		default:
-			return nil, fmt.Errorf("cannot navigate path at %q", part)
+			depth--
+		}
+	}
+	return -1
+}
+
+// extractValue evaluates a JSONPath expression or dot path (e.g.
+// "structuredContent.items.0.id") on the last response. A path that selects at
+// most one value returns it; wildcards, filters, slices and recursive descent
+// return the list of matches.
+func (r *Runner) extractValue(path string) (any, error) {
+	if path == "rawResponse" {
+		return r.lastResponse, nil
+	}
+
+	if r.lastRawMap == nil {
+		return nil, fmt.Errorf("no previous response available")
+	}
+
+	p, err := compilePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths are tried on the whole response first. "structuredContent." may
+	// be left out, and is ignored for responses without structured content.
+	roots := []*jsonPath{p}
+	if len(p.steps) > 0 && p.steps[0].kind == stepKey && p.steps[0].key == "structuredContent" && !p.steps[0].recursive {
+		roots = append(roots, &jsonPath{expr: p.expr, steps: p.steps[1:]})
+	}
+	structured, hasStructured := r.lastRawMap["structuredContent"]
+
+	if !p.definite() {
+		for _, q := range roots {
+			if matches := q.query(r.lastRawMap); len(matches) > 0 {
+				return matches, nil
+			}
+		}
+		if hasStructured {
+			if matches := p.query(structured); len(matches) > 0 {
+				return matches, nil
+			}
+		}
+		return []any{}, nil
+	}
+
+	// If no attempt matches, the error of the one that got furthest is
+	// the most helpful.
+	var best *pathError
+	try := func(q *jsonPath, root any) (any, bool) {
+		val, err := q.get(root)
+		if err == nil {
+			return val, true
+		}
+		if pe, ok := err.(*pathError); ok && (best == nil || pe.depth > best.depth) {
+			best = pe
+		}
+		return nil, false
+	}
+	for _, q := range roots {
+		if val, ok := try(q, r.lastRawMap); ok {
+			return val, nil
 		}
 	}
-	if current == nil {
-		return nil, fmt.Errorf("value at path %q is nil", path)
+	if hasStructured {
+		if val, ok := try(p, structured); ok {
+			return val, nil
+		}
+	}
+	return nil, best
+}
+
+func (r *Runner) handleDumpVarsCommand(lineIdx int, parts []string) error {
+	if len(parts) != 1 {
+		return fmt.Errorf("line %d: dump_vars expects no arguments", lineIdx+1)
+	}
+	r.DumpVariables(r.out())
+	return nil
+}
+
+// DumpVariables writes the visible variables sorted by name, one per line
+// with their JSON type and value. Local variables are marked as such.
+func (r *Runner) DumpVariables(w io.Writer) {
+	vars := r.Variables()
+	if len(vars) == 0 {
+		fmt.Fprintln(w, "No variables set.")
+		return
+	}
+	local := make(map[string]bool)
+	for _, s := range r.visibleScopes() {
+		for name := range s.vars {
+			local[name] = true
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kind := jsonType(vars[name])
+		if local[name] {
+			kind += ", local"
+		}
+		b, _ := encodeJSON(vars[name], "")
+		fmt.Fprintf(w, "%s (%s) = %s", name, kind, b)
 	}
-	return current, nil
 }
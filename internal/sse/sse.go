@@ -0,0 +1,68 @@
+// Package sse parses Server-Sent Events streams as used by the MCP HTTP
+// transports.
+package sse
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Event is a dispatched event. Name is empty for events without an "event"
+// field, which the specification treats as "message".
+type Event struct {
+	Name string
+	Data string
+}
+
+// Parser splits a stream into events. Bytes are fed as they arrive, so a
+// stream can be parsed while it is read. The zero value is ready to use.
+type Parser struct {
+	buf     []byte
+	name    string
+	data    []string
+	hasData bool
+}
+
+// Feed adds the next bytes of the stream and returns the events completed by
+// them.
+func (p *Parser) Feed(b []byte) []Event {
+	p.buf = append(p.buf, b...)
+	var events []Event
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return events
+		}
+		line := strings.TrimSuffix(string(p.buf[:i]), "\r")
+		p.buf = p.buf[i+1:]
+		if ev, ok := p.line(line); ok {
+			events = append(events, ev)
+		}
+	}
+}
+
+// line processes one line and reports whether it completed an event.
+func (p *Parser) line(line string) (Event, bool) {
+	if line == "" {
+		if !p.hasData {
+			p.name = ""
+			return Event{}, false
+		}
+		ev := Event{Name: p.name, Data: strings.Join(p.data, "\n")}
+		p.name, p.data, p.hasData = "", nil, false
+		return ev, true
+	}
+	if strings.HasPrefix(line, ":") {
+		return Event{}, false // comment
+	}
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch field {
+	case "event":
+		p.name = value
+	case "data":
+		p.data = append(p.data, value)
+		p.hasData = true
+	}
+	return Event{}, false
+}
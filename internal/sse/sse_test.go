@@ -0,0 +1,24 @@
+package sse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	stream := ": keep-alive\n\nevent: endpoint\ndata: /message?id=1\n\ndata: {\"a\":\r\ndata: 1}\r\n\r\nid: 7\ndata:{}\n\ndata: partial"
+	var p Parser
+	var got []Event
+	// Feed the stream in small chunks, as it arrives from the network.
+	for i := 0; i < len(stream); i += 5 {
+		got = append(got, p.Feed([]byte(stream[i:min(i+5, len(stream))]))...)
+	}
+	want := []Event{
+		{Name: "endpoint", Data: "/message?id=1"},
+		{Data: "{\"a\":\n1}"},
+		{Data: "{}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/hmsoft0815/mlc_mcptester/internal/sse"
+)
+
+// sessionIDHeader carries the session of a Streamable HTTP connection.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// RoundTripper records the JSON-RPC messages in the HTTP requests and
+// responses of an SSE or Streamable HTTP connection: the bodies of POST
+// requests, JSON responses and the message events of SSE streams. Use one
+// RoundTripper per connection.
+type RoundTripper struct {
+	Base http.RoundTripper
+	rec  *recorder
+}
+
+// NewRoundTripper returns a RoundTripper that sends requests through base,
+// or http.DefaultTransport if base is nil.
+func NewRoundTripper(base http.RoundTripper, w *Writer) *RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RoundTripper{Base: base, rec: newRecorder(w)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Method == http.MethodPost {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.rec.record(DirectionSend, data, req.Header.Get(sessionIDHeader))
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := resp.Header.Get(sessionIDHeader)
+	if sessionID == "" {
+		sessionID = req.Header.Get(sessionIDHeader)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.rec.record(DirectionRecv, data, sessionID)
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+	case "text/event-stream":
+		// Streams stay open, so events are recorded as they are read.
+		resp.Body = &eventReader{ReadCloser: resp.Body, rec: t.rec, sessionID: sessionID}
+	}
+	return resp, nil
+}
+
+// eventReader records the message events of an SSE stream as it is read.
+// Other events, such as the endpoint event of the SSE transport, carry no
+// JSON-RPC message.
+type eventReader struct {
+	io.ReadCloser
+	rec       *recorder
+	sessionID string
+	parser    sse.Parser
+}
+
+func (r *eventReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	for _, ev := range r.parser.Feed(p[:n]) {
+		if ev.Name == "" || ev.Name == "message" {
+			r.rec.record(DirectionRecv, []byte(ev.Data), r.sessionID)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,314 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Status of a replayed request.
+const (
+	StatusMatch   = "match"
+	StatusDiff    = "diff"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// missing marks a value that is absent on one side of a comparison.
+const missing = "<missing>"
+
+// Diff is a single difference between the recorded and the replayed response.
+// Values are JSON-encoded, or "<missing>" if the path does not exist.
+type Diff struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Result is the outcome of replaying one request.
+type Result struct {
+	Conn      int     `json:"conn,omitempty"`
+	Method    string  `json:"method"`
+	ID        any     `json:"id"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs,omitempty"`
+	Diffs     []Diff  `json:"diffs,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Ignore lists dot paths that are excluded from the comparison, relative to
+	// the response message, e.g. "result.serverInfo.version". A "*" segment
+	// matches any key or array index.
+	Ignore []string
+	// Timeout limits the wait for each response. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Connections splits the records of a trace by connection, in the order in
+// which the connections started.
+func Connections(records []Record) [][]Record {
+	var conns [][]Record
+	index := make(map[int]int)
+	for _, rec := range records {
+		i, ok := index[rec.Conn]
+		if !ok {
+			i = len(conns)
+			index[rec.Conn] = i
+			conns = append(conns, nil)
+		}
+		conns[i] = append(conns[i], rec)
+	}
+	return conns
+}
+
+// ReplayConnections replays every connection of a trace over its own fresh
+// connection from connect, one after the other. Recorded request IDs are only
+// unique within a connection, so each is compared with its own responses.
+func ReplayConnections(ctx context.Context, connect func(context.Context) (mcp.Connection, error), records []Record, opts ReplayOptions) ([]Result, error) {
+	var results []Result
+	for _, recs := range Connections(records) {
+		conn, err := connect(ctx)
+		if err != nil {
+			return results, fmt.Errorf("failed to connect: %w", err)
+		}
+		res, err := Replay(ctx, conn, recs, opts)
+		conn.Close()
+		for i := range res {
+			res[i].Conn = recs[0].Conn
+		}
+		results = append(results, res...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Replay sends the client-side requests and notifications of a trace over conn,
+// in their recorded order, and compares each response to the recorded one.
+// records must come from a single connection, see ReplayConnections. conn
+// must be a fresh connection: the recorded initialize handshake is
+// replayed as well. Requests sent by the server during the replay are answered
+// with an empty result for ping and "method not found" otherwise.
+func Replay(ctx context.Context, conn mcp.Connection, records []Record, opts ReplayOptions) ([]Result, error) {
+	recorded := make(map[string]json.RawMessage)
+	for _, rec := range records {
+		if rec.Direction == DirectionRecv && rec.Kind == KindResponse {
+			recorded[idKey(rec.ID)] = rec.Message
+		}
+	}
+
+	var results []Result
+	for _, rec := range records {
+		if rec.Direction != DirectionSend || rec.Kind == KindResponse {
+			continue
+		}
+		msg, err := jsonrpc.DecodeMessage(rec.Message)
+		if err != nil {
+			return results, fmt.Errorf("invalid %s message in trace: %w", rec.Method, err)
+		}
+		if err := conn.Write(ctx, msg); err != nil {
+			return results, fmt.Errorf("failed to send %s: %w", rec.Method, err)
+		}
+		if rec.Kind == KindNotification {
+			continue
+		}
+
+		res := Result{Method: rec.Method, ID: rec.ID}
+		expected, ok := recorded[idKey(rec.ID)]
+		if !ok {
+			// The original request never got an answer, e.g. it was cancelled.
+			res.Status = StatusSkipped
+			results = append(results, res)
+			continue
+		}
+
+		start := time.Now()
+		actual, err := awaitResponse(ctx, conn, msg.(*jsonrpc.Request).ID, opts.Timeout)
+		res.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		if err != nil {
+			res.Status = StatusError
+			res.Error = err.Error()
+			results = append(results, res)
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			continue
+		}
+
+		res.Diffs, err = CompareMessages(expected, actual, opts.Ignore)
+		if err != nil {
+			return results, err
+		}
+		res.Status = StatusMatch
+		if len(res.Diffs) > 0 {
+			res.Status = StatusDiff
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// awaitResponse reads until the response to id arrives.
+func awaitResponse(ctx context.Context, conn mcp.Connection, id jsonrpc.ID, timeout time.Duration) (json.RawMessage, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	for {
+		msg, err := conn.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch m := msg.(type) {
+		case *jsonrpc.Response:
+			if m.ID == id {
+				return jsonrpc.EncodeMessage(m)
+			}
+		case *jsonrpc.Request:
+			if m.ID.IsValid() {
+				if err := conn.Write(ctx, answerServerRequest(m)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+func answerServerRequest(req *jsonrpc.Request) *jsonrpc.Response {
+	if req.Method == "ping" {
+		return &jsonrpc.Response{ID: req.ID, Result: json.RawMessage("{}")}
+	}
+	return &jsonrpc.Response{ID: req.ID, Error: &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "not supported during replay"}}
+}
+
+// idKey normalizes a request ID from a decoded record, where numbers are float64.
+func idKey(id any) string {
+	if f, ok := id.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%q", id)
+}
+
+// CompareMessages compares two JSON-RPC response messages, ignoring the
+// "jsonrpc" and "id" members and the given paths.
+func CompareMessages(expected, actual json.RawMessage, ignore []string) ([]Diff, error) {
+	var exp, act map[string]any
+	if err := json.Unmarshal(expected, &exp); err != nil {
+		return nil, fmt.Errorf("invalid recorded response: %w", err)
+	}
+	if err := json.Unmarshal(actual, &act); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	for _, m := range []map[string]any{exp, act} {
+		delete(m, "jsonrpc")
+		delete(m, "id")
+	}
+	return Compare(exp, act, ignore), nil
+}
+
+// Compare returns the differences between two decoded JSON values. Paths
+// matching an ignore pattern are skipped together with everything below them.
+func Compare(expected, actual any, ignore []string) []Diff {
+	var patterns [][]string
+	for _, p := range ignore {
+		patterns = append(patterns, strings.Split(p, "."))
+	}
+	var diffs []Diff
+	compare(nil, expected, actual, true, true, patterns, &diffs)
+	return diffs
+}
+
+func compare(path []string, exp, act any, hasExp, hasAct bool, ignore [][]string, diffs *[]Diff) {
+	if ignored(path, ignore) {
+		return
+	}
+	if hasExp && hasAct {
+		switch e := exp.(type) {
+		case map[string]any:
+			if a, ok := act.(map[string]any); ok {
+				keys := make(map[string]bool)
+				for k := range e {
+					keys[k] = true
+				}
+				for k := range a {
+					keys[k] = true
+				}
+				sorted := make([]string, 0, len(keys))
+				for k := range keys {
+					sorted = append(sorted, k)
+				}
+				sort.Strings(sorted)
+				for _, k := range sorted {
+					ev, eok := e[k]
+					av, aok := a[k]
+					compare(append(path, k), ev, av, eok, aok, ignore, diffs)
+				}
+				return
+			}
+		case []any:
+			if a, ok := act.([]any); ok {
+				for i := 0; i < max(len(e), len(a)); i++ {
+					var ev, av any
+					if i < len(e) {
+						ev = e[i]
+					}
+					if i < len(a) {
+						av = a[i]
+					}
+					compare(append(path, strconv.Itoa(i)), ev, av, i < len(e), i < len(a), ignore, diffs)
+				}
+				return
+			}
+		}
+		if reflect.DeepEqual(exp, act) {
+			return
+		}
+	}
+	*diffs = append(*diffs, Diff{
+		Path:     strings.Join(path, "."),
+		Expected: encodeValue(exp, hasExp),
+		Actual:   encodeValue(act, hasAct),
+	})
+}
+
+func ignored(path []string, ignore [][]string) bool {
+	for _, pattern := range ignore {
+		if len(pattern) != len(path) {
+			continue
+		}
+		match := true
+		for i, seg := range pattern {
+			if seg != "*" && seg != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeValue(v any, present bool) string {
+	if !present {
+		return missing
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,157 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCompare(t *testing.T) {
+	decode := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		ignore   []string
+		want     []string
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, nil, nil},
+		{"changed value", `{"a":{"b":"x"}}`, `{"a":{"b":"y"}}`, nil, []string{`a.b: "x" -> "y"`}},
+		{"missing key", `{"a":1}`, `{}`, nil, []string{`a: 1 -> <missing>`}},
+		{"extra element", `[1]`, `[1,2]`, nil, []string{`1: <missing> -> 2`}},
+		{"type change", `{"a":[1]}`, `{"a":"1"}`, nil, []string{`a: [1] -> "1"`}},
+		{"ignored path", `{"a":{"ts":1,"v":2}}`, `{"a":{"ts":5,"v":2}}`, []string{"a.ts"}, nil},
+		{"ignored wildcard", `{"c":[{"id":1},{"id":2}]}`, `{"c":[{"id":3},{"id":4}]}`, []string{"c.*.id"}, nil},
+		{"ignored subtree", `{"meta":{"x":1}}`, `{"meta":{"y":2}}`, []string{"meta"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range Compare(decode(tt.expected), decode(tt.actual), tt.ignore) {
+				got = append(got, d.Path+": "+d.Expected+" -> "+d.Actual)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Compare() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+
+	// Record a session.
+	var buf bytes.Buffer
+	r, w, _ := connectPipes(t, newEchoServer("Echo: "))
+	transport := &IOTransport{Reader: r, Writer: w, Trace: NewWriter(&buf)}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, transport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+
+	replay := func(prefix string, ignore ...string) []Result {
+		r, w, _ := connectPipes(t, newEchoServer(prefix))
+		conn, err := (&mcp.IOTransport{Reader: r, Writer: w}).Connect(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		results, err := Replay(ctx, conn, records, ReplayOptions{Ignore: ignore})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	results := replay("Echo: ")
+	if len(results) != 2 || results[0].Method != "initialize" || results[1].Method != "tools/call" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for _, res := range results {
+		if res.Status != StatusMatch {
+			t.Errorf("%s: status %s, diffs %+v", res.Method, res.Status, res.Diffs)
+		}
+	}
+
+	results = replay("Changed: ")
+	if results[1].Status != StatusDiff || len(results[1].Diffs) != 1 || results[1].Diffs[0].Path != "result.content.0.text" {
+		t.Errorf("expected a diff in the tool result, got %+v", results[1])
+	}
+
+	results = replay("Changed: ", "result.content.*.text")
+	if results[1].Status != StatusMatch {
+		t.Errorf("ignored path must not be reported, got %+v", results[1])
+	}
+}
+
+func TestReplayConnections(t *testing.T) {
+	ctx := context.Background()
+
+	// Two sessions share a trace, with the same request IDs but different
+	// responses.
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	for _, msg := range []string{"one", "two"} {
+		r, w, _ := connectPipes(t, newEchoServer(""))
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, &IOTransport{Reader: r, Writer: w, Trace: writer}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": msg}}); err != nil {
+			t.Fatal(err)
+		}
+		session.Close()
+	}
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	if conns := Connections(records); len(conns) != 2 || conns[0][0].Conn != 1 || conns[1][0].Conn != 2 {
+		t.Fatalf("unexpected connections %+v", conns)
+	}
+
+	connect := func(ctx context.Context) (mcp.Connection, error) {
+		r, w, _ := connectPipes(t, newEchoServer(""))
+		return (&mcp.IOTransport{Reader: r, Writer: w}).Connect(ctx)
+	}
+	results, err := ReplayConnections(ctx, connect, records, ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, res := range results {
+		got = append(got, fmt.Sprintf("%d %s %s", res.Conn, res.Method, res.Status))
+	}
+	want := []string{"1 initialize match", "1 tools/call match", "2 initialize match", "2 tools/call match"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("results = %q; want %q", got, want)
+	}
+}
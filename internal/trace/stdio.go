@@ -0,0 +1,137 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// terminateDuration is how long Close waits for a server process to exit
+// after closing its stdin, and again after SIGTERM, as mcp.CommandTransport.
+const terminateDuration = 5 * time.Second
+
+// IOTransport is an mcp.IOTransport that records the newline-delimited
+// messages read from Reader and written to Writer.
+type IOTransport struct {
+	Reader io.ReadCloser
+	Writer io.WriteCloser
+	Trace  *Writer
+}
+
+// Connect implements mcp.Transport.
+func (t *IOTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	rec := newRecorder(t.Trace)
+	return (&mcp.IOTransport{
+		Reader: &lineReader{ReadCloser: t.Reader, rec: rec},
+		Writer: &lineWriter{WriteCloser: t.Writer, rec: rec},
+	}).Connect(ctx)
+}
+
+// CommandTransport runs a server command like mcp.CommandTransport and
+// records the messages on its stdin and stdout.
+type CommandTransport struct {
+	Command *exec.Cmd
+	Trace   *Writer
+}
+
+// Connect starts the command and connects to it over stdin/stdout.
+func (t *CommandTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	stdout, err := t.Command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := t.Command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Command.Start(); err != nil {
+		return nil, err
+	}
+	// The connection is closed by closing stdin, not stdout.
+	return (&IOTransport{
+		Reader: io.NopCloser(stdout),
+		Writer: &process{WriteCloser: stdin, cmd: t.Command},
+		Trace:  t.Trace,
+	}).Connect(ctx)
+}
+
+// process closes the stdin of a server and waits for it to exit, sending
+// SIGTERM and then SIGKILL if it does not.
+type process struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	if err := p.WriteCloser.Close(); err != nil {
+		return fmt.Errorf("closing stdin: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	wait := func() (error, bool) {
+		select {
+		case err := <-done:
+			return err, true
+		case <-time.After(terminateDuration):
+			return nil, false
+		}
+	}
+	if err, ok := wait(); ok {
+		return err
+	}
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		if err, ok := wait(); ok {
+			return err
+		}
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	if err, ok := wait(); ok {
+		return err
+	}
+	return fmt.Errorf("unresponsive subprocess")
+}
+
+// lineReader records every complete line read from a stream.
+type lineReader struct {
+	io.ReadCloser
+	rec *recorder
+	buf []byte
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf = recordLines(r.rec, DirectionRecv, append(r.buf, p[:n]...))
+	return n, err
+}
+
+// lineWriter records every complete line written to a stream.
+type lineWriter struct {
+	io.WriteCloser
+	rec *recorder
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = recordLines(w.rec, DirectionSend, append(w.buf, p...))
+	return w.WriteCloser.Write(p)
+}
+
+// recordLines records the complete lines in buf and returns the rest.
+func recordLines(rec *recorder, direction string, buf []byte) []byte {
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return buf
+		}
+		rec.record(direction, buf[:i], "")
+		buf = buf[i+1:]
+	}
+}
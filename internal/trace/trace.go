@@ -0,0 +1,213 @@
+// Package trace records the JSON-RPC messages exchanged with an MCP server
+// as JSON Lines.
+//
+// Messages are recorded below the SDK, as they are written to and read from
+// the wire: CommandTransport and IOTransport tap the streams of stdio servers
+// and RoundTripper the HTTP requests of the SSE and Streamable HTTP
+// transports. The SDK's own connections are used unchanged, so stdio, SSE
+// and Streamable HTTP sessions behave the same with and without tracing.
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+)
+
+// Directions of a record, seen from the client.
+const (
+	DirectionSend = "send"
+	DirectionRecv = "recv"
+)
+
+// Kinds of JSON-RPC messages.
+const (
+	KindRequest      = "request"
+	KindNotification = "notification"
+	KindResponse     = "response"
+)
+
+// Record is one line of a trace file.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Conn numbers the connections of a trace file from 1, since several
+	// sessions, e.g. of parallel scripts, may share it. Traces without it
+	// hold a single connection.
+	Conn      int    `json:"conn,omitempty"`
+	Direction string `json:"direction"`
+	SessionID string `json:"sessionId,omitempty"`
+	Kind      string `json:"kind"`
+	ID        any    `json:"id,omitempty"`
+	// Method is the method of a request or notification. Responses carry the
+	// method of the request they answer.
+	Method string `json:"method,omitempty"`
+	// LatencyMS is set on responses: the milliseconds since the matching
+	// request was sent or received.
+	LatencyMS *float64        `json:"latencyMs,omitempty"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Writer serializes records to an io.Writer. It is safe for concurrent use,
+// so several connections may share one trace file.
+type Writer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	conns int
+}
+
+// NewWriter returns a Writer that writes one JSON record per line to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write appends a record.
+func (w *Writer) Write(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+// newConn returns the number of a new connection.
+func (w *Writer) newConn() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conns++
+	return w.conns
+}
+
+// Close closes the underlying writer if it is an io.Closer. Records written
+// after Close are lost.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type pendingRequest struct {
+	method string
+	start  time.Time
+}
+
+// recorder writes the messages of one connection. Requests are remembered by
+// direction and ID until their response arrives, to compute the latency.
+type recorder struct {
+	writer *Writer
+	conn   int
+
+	mu      sync.Mutex
+	pending map[string]pendingRequest
+}
+
+func newRecorder(w *Writer) *recorder {
+	return &recorder{writer: w, conn: w.newConn(), pending: make(map[string]pendingRequest)}
+}
+
+// record writes the messages in data as they were sent or received. A batch
+// is recorded as one record per message. Tracing must never break the
+// session, so invalid payloads and write errors are ignored.
+func (c *recorder) record(direction string, data []byte, sessionID string) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return
+	}
+	if data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err == nil {
+			for _, m := range batch {
+				c.record(direction, m, sessionID)
+			}
+		}
+		return
+	}
+
+	now := time.Now()
+	rec := &Record{
+		Time:      now,
+		Conn:      c.conn,
+		Direction: direction,
+		SessionID: sessionID,
+		Message:   data,
+	}
+	msg, err := jsonrpc.DecodeMessage(data)
+	if err != nil {
+		_ = c.writer.Write(rec)
+		return
+	}
+	switch m := msg.(type) {
+	case *jsonrpc.Request:
+		rec.Method = m.Method
+		rec.Kind = KindNotification
+		if m.ID.IsValid() {
+			rec.Kind = KindRequest
+			rec.ID = m.ID.Raw()
+			c.mu.Lock()
+			c.pending[pendingKey(direction, m.ID)] = pendingRequest{method: m.Method, start: now}
+			c.mu.Unlock()
+		}
+	case *jsonrpc.Response:
+		rec.Kind = KindResponse
+		rec.ID = m.ID.Raw()
+		// A response answers a request that travelled the opposite way.
+		requestDirection := DirectionSend
+		if direction == DirectionSend {
+			requestDirection = DirectionRecv
+		}
+		key := pendingKey(requestDirection, m.ID)
+		c.mu.Lock()
+		req, ok := c.pending[key]
+		delete(c.pending, key)
+		c.mu.Unlock()
+		if ok {
+			rec.Method = req.method
+			latency := float64(now.Sub(req.start).Microseconds()) / 1000
+			rec.LatencyMS = &latency
+		}
+	}
+	_ = c.writer.Write(rec)
+}
+
+// pendingKey distinguishes numeric and string IDs with the same text.
+func pendingKey(direction string, id jsonrpc.ID) string {
+	return fmt.Sprintf("%s:%T:%v", direction, id.Raw(), id.Raw())
+}
+
+// ReadFile loads all records of a trace file.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid trace record: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newEchoServer(prefix string) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "1.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "echo"}, func(_ context.Context, _ *mcp.CallToolRequest, args map[string]any) (*mcp.CallToolResult, any, error) {
+		msg, _ := args["message"].(string)
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: prefix + msg}}}, nil, nil
+	})
+	return server
+}
+
+// connectPipes connects server over a pair of pipes and returns the client
+// ends of them.
+func connectPipes(t *testing.T, server *mcp.Server) (io.ReadCloser, io.WriteCloser, *mcp.ServerSession) {
+	t.Helper()
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+	session, err := server.Connect(context.Background(), &mcp.IOTransport{Reader: serverRead, Writer: serverWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return clientRead, clientWrite, session
+}
+
+func checkRecords(t *testing.T, records []Record, want []struct{ direction, kind, method string }) {
+	t.Helper()
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, w := range want {
+		rec := records[i]
+		if rec.Direction != w.direction || rec.Kind != w.kind || rec.Method != w.method {
+			t.Errorf("record %d = %s %s %s; want %s %s %s", i, rec.Direction, rec.Kind, rec.Method, w.direction, w.kind, w.method)
+		}
+		if (rec.Kind == KindResponse) != (rec.LatencyMS != nil) {
+			t.Errorf("record %d: latency must be set on responses only, got %v", i, rec.LatencyMS)
+		}
+		if len(rec.Message) == 0 || rec.Time.IsZero() {
+			t.Errorf("record %d is missing message or time", i)
+		}
+	}
+}
+
+func TestIOTransport(t *testing.T) {
+	ctx := context.Background()
+	r, w, serverSession := connectPipes(t, newEchoServer(""))
+
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(f)
+
+	transport := &IOTransport{Reader: r, Writer: w, Trace: writer}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, transport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	// A server-initiated ping is paired in the opposite direction.
+	if err := serverSession.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []struct{ direction, kind, method string }{
+		{DirectionSend, KindRequest, "initialize"},
+		{DirectionRecv, KindResponse, "initialize"},
+		{DirectionSend, KindNotification, "notifications/initialized"},
+		{DirectionSend, KindRequest, "tools/call"},
+		{DirectionRecv, KindResponse, "tools/call"},
+		{DirectionRecv, KindRequest, "ping"},
+		{DirectionSend, KindResponse, "ping"},
+	})
+}
+
+func TestRoundTripper(t *testing.T) {
+	ctx := context.Background()
+	server := newEchoServer("")
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+	var mu sync.Mutex
+	var versions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			mu.Lock()
+			versions = append(versions, req.Header.Get("Mcp-Protocol-Version"))
+			mu.Unlock()
+		}
+		handler.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: NewRoundTripper(nil, NewWriter(&buf))}
+	transport := &mcp.StreamableClientTransport{Endpoint: srv.URL, HTTPClient: client}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "1.0"}, nil).Connect(ctx, transport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	checkRecords(t, records, []struct{ direction, kind, method string }{
+		{DirectionSend, KindRequest, "initialize"},
+		{DirectionRecv, KindResponse, "initialize"},
+		{DirectionSend, KindNotification, "notifications/initialized"},
+		{DirectionSend, KindRequest, "tools/call"},
+		{DirectionRecv, KindResponse, "tools/call"},
+	})
+	if records[3].SessionID == "" {
+		t.Error("the session ID of the Streamable HTTP session is not recorded")
+	}
+	// Tracing leaves the session unchanged: requests after initialize carry the
+	// negotiated protocol version.
+	mu.Lock()
+	defer mu.Unlock()
+	for i, v := range versions[1:] {
+		if v == "" {
+			t.Errorf("POST %d was sent without Mcp-Protocol-Version", i+2)
+		}
+	}
+}
+
+func TestRecordLines(t *testing.T) {
+	var buf bytes.Buffer
+	rec := newRecorder(NewWriter(&buf))
+	// Messages are recorded as received, including members the SDK drops,
+	// and split at newlines regardless of how the stream is chunked.
+	rest := recordLines(rec, DirectionRecv, []byte(`{"jsonrpc":"2.0","method":"x","vendor":1}`+"\n"+`{"jsonrpc":"2.0",`))
+	rest = recordLines(rec, DirectionRecv, append(rest, `"method":"y"}`+"\n"...))
+	if len(rest) != 0 {
+		t.Errorf("unrecorded rest %q", rest)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"message":{"jsonrpc":"2.0","method":"x","vendor":1}`) || !strings.Contains(lines[1], `"method":"y"`) {
+		t.Errorf("records = %q", lines)
+	}
+}